@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	BadgeFirstWin      = "first-win"
+	BadgeWinStreak     = "10-win-streak"
+	BadgeCentury       = "100-wins"
+	BadgeBeatTheLeader = "beat-the-leader"
+)
+
+type Achievement struct {
+	Badge     string    `json:"badge"`
+	AwardedAt time.Time `json:"awardedAt"`
+}
+
+// WinContext describes a win as the rules see it. Before and Wins are
+// the player's score ahead of and after the win, which may have been
+// worth several. Streak counts the player's wins since anyone else last
+// won, and RivalWins is the best score of any other player before this
+// win.
+type WinContext struct {
+	Player    string
+	Before    int
+	Wins      int
+	Streak    int
+	RivalWins int
+}
+
+type Rule struct {
+	Badge   string
+	Awarded func(WinContext) bool
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		{BadgeFirstWin, func(c WinContext) bool { return c.Wins >= 1 }},
+		{BadgeWinStreak, func(c WinContext) bool { return c.Streak >= 10 }},
+		{BadgeCentury, func(c WinContext) bool { return c.Wins >= 100 }},
+		{BadgeBeatTheLeader, func(c WinContext) bool { return c.RivalWins > 0 && c.Before <= c.RivalWins && c.Wins > c.RivalWins }},
+	}
+}
+
+// AchievementStore keeps awarded badges. Player stores that implement it
+// keep badges next to the wins, so they survive a restart.
+type AchievementStore interface {
+	Achievements(name string) []Achievement
+	Award(name string, a Achievement) bool
+}
+
+// WinStreaks is implemented by player stores that count streaks from
+// the wins they record.
+type WinStreaks interface {
+	Streak(name string) int
+}
+
+type InMemoryAchievementStore struct {
+	mu      sync.Mutex
+	awarded map[string][]Achievement
+}
+
+func NewInMemoryAchievementStore() *InMemoryAchievementStore {
+	return &InMemoryAchievementStore{awarded: make(map[string][]Achievement)}
+}
+
+func (s *InMemoryAchievementStore) Achievements(name string) []Achievement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Achievement(nil), s.awarded[name]...)
+}
+
+// Award records the achievement unless the player already holds the
+// badge, and reports whether it was new.
+func (s *InMemoryAchievementStore) Award(name string, a Achievement) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holdsBadge(s.awarded[name], a.Badge) {
+		return false
+	}
+	s.awarded[name] = append(s.awarded[name], a)
+	return true
+}
+
+func holdsBadge(achievements []Achievement, badge string) bool {
+	for _, held := range achievements {
+		if held.Badge == badge {
+			return true
+		}
+	}
+	return false
+}
+
+// advanceStreaks extends each winner's streak and ends everyone else's.
+// A name given n times extends its streak by n.
+func advanceStreaks(streaks map[string]int, names ...string) {
+	winners := make(map[string]bool)
+	for _, name := range names {
+		winners[name] = true
+		streaks[name]++
+	}
+	for name := range streaks {
+		if !winners[name] {
+			delete(streaks, name)
+		}
+	}
+}
+
+// AchievementEngine runs the rules after every win and awards any badge
+// a player has earned.
+type AchievementEngine struct {
+	Store AchievementStore
+	rules []Rule
+
+	mu      sync.Mutex
+	streaks map[string]int
+}
+
+func NewAchievementEngine(rules ...Rule) *AchievementEngine {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &AchievementEngine{
+		Store:   NewInMemoryAchievementStore(),
+		rules:   rules,
+		streaks: make(map[string]int),
+	}
+}
+
+// OnWin evaluates a win shared by every named player, like a team win
+// or a batch, given their scores before it. It returns the newly awarded
+// achievements.
+func (e *AchievementEngine) OnWin(store PlayerStore, before, rivalWins map[string]int, names ...string) map[string][]Achievement {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	wins := make(map[string]int, len(names))
+	var steps []string
+	for _, name := range names {
+		if _, seen := wins[name]; seen {
+			continue
+		}
+		wins[name] = store.GetPlayerScore(name)
+		for i := 0; i < max(wins[name]-before[name], 1); i++ {
+			steps = append(steps, name)
+		}
+	}
+	advanceStreaks(e.streaks, steps...)
+	streak := func(name string) int { return e.streaks[name] }
+	if s, ok := store.(WinStreaks); ok {
+		streak = s.Streak
+	}
+
+	now := time.Now().UTC()
+	awarded := make(map[string][]Achievement)
+	for name := range wins {
+		c := WinContext{
+			Player:    name,
+			Before:    before[name],
+			Wins:      wins[name],
+			Streak:    streak(name),
+			RivalWins: rivalWins[name],
+		}
+		for _, rule := range e.rules {
+			if !rule.Awarded(c) {
+				continue
+			}
+			a := Achievement{Badge: rule.Badge, AwardedAt: now}
+			if e.Store.Award(name, a) {
+				awarded[name] = append(awarded[name], a)
+			}
+		}
+	}
+	return awarded
+}
+
+func (e *AchievementEngine) Badges(name string) []string {
+	var badges []string
+	for _, a := range e.Store.Achievements(name) {
+		badges = append(badges, a.Badge)
+	}
+	return badges
+}
+
+// WithAchievements awards badges after each win and lists them in the
+// league and under /players/{name}/achievements. When the player store
+// is an AchievementStore the engine keeps its badges there instead.
+func WithAchievements(e *AchievementEngine) ServerOption {
+	return func(p *PlayerServer) {
+		if store, ok := p.store.(AchievementStore); ok {
+			e.Store = store
+		}
+		p.achievements = e
+	}
+}
+
+// rivalWins finds, for each named player, the best score of anyone else.
+func (p *PlayerServer) rivalWins(names ...string) map[string]int {
+	if p.achievements == nil {
+		return nil
+	}
+	rivals := make(map[string]int, len(names))
+	for _, name := range names {
+		rivals[name] = p.podium.rival(name)
+	}
+	return rivals
+}
+
+func (p *PlayerServer) awardAchievements(before, rivals map[string]int, names ...string) {
+	if p.achievements == nil {
+		return
+	}
+	p.achievements.OnWin(p.store, before, rivals, names...)
+}
+
+func (p *PlayerServer) withAchievements(league []Player) []Player {
+	if p.achievements == nil {
+		return league
+	}
+	for i := range league {
+		league[i].Achievements = p.achievements.Badges(league[i].Name)
+	}
+	return league
+}
+
+func (p *PlayerServer) achievementsHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if p.achievements == nil {
+		http.Error(w, "achievements are not enabled", http.StatusNotImplemented)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	achievements := p.achievements.Store.Achievements(player)
+	if achievements == nil {
+		achievements = []Achievement{}
+	}
+	writeJSON(w, http.StatusOK, achievements)
+}
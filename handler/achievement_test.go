@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAchievements(t *testing.T) {
+	t.Run("awards a first win once", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(engine))
+
+		recordWins(server, "Pepper", "Pepper")
+
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin})
+	})
+
+	t.Run("awards a streak only for consecutive wins", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(engine))
+
+		for i := 0; i < 9; i++ {
+			recordWins(server, "Pepper")
+		}
+		recordWins(server, "Floyd")
+		for i := 0; i < 9; i++ {
+			recordWins(server, "Pepper")
+		}
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin})
+
+		recordWins(server, "Pepper")
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeWinStreak})
+	})
+
+	t.Run("awards a century", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		store := &StubPlayerStore{scores: map[string]int{"Pepper": 99}}
+		server := NewPlayerServer(store, WithAchievements(engine))
+
+		recordWins(server, "Pepper")
+
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeCentury})
+	})
+
+	t.Run("awards overtaking the leader", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(engine))
+
+		recordWins(server, "Floyd", "Floyd", "Pepper", "Pepper")
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin})
+
+		recordWins(server, "Pepper")
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeBeatTheLeader})
+	})
+
+	t.Run("awards overtaking the leader in one batch", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(engine))
+		server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Floyd", "wins": 6}, {"name": "Pepper", "wins": 5}]`))
+
+		server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Pepper", "wins": 3}]`))
+
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeBeatTheLeader})
+	})
+
+	t.Run("counts every win of a batch towards a streak", func(t *testing.T) {
+		for name, store := range map[string]PlayerStore{
+			"memory": NewInMemoryPlayerStore(),
+			"events": newTestEventStore(t, NewInMemoryEventLog()),
+		} {
+			t.Run(name, func(t *testing.T) {
+				engine := NewAchievementEngine()
+				server := NewPlayerServer(store, WithAchievements(engine))
+
+				server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Pepper", "wins": 10}]`))
+
+				assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeWinStreak})
+			})
+		}
+	})
+
+	t.Run("finds rivals without reading the league on every win", func(t *testing.T) {
+		store := &leagueCountingStore{InMemoryPlayerStore: NewInMemoryPlayerStore()}
+		engine := NewAchievementEngine()
+		server := NewPlayerServer(store, WithAchievements(engine), WithWebhooks(newTestDispatcher()))
+
+		recordWins(server, "Floyd", "Pepper", "Pepper")
+
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeBeatTheLeader})
+		assertScoreEquals(t, store.leagues, 1)
+	})
+
+	t.Run("credits every member of a winning team", func(t *testing.T) {
+		engine := NewAchievementEngine()
+		store := NewInMemoryPlayerStore()
+		store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}})
+		server := NewPlayerServer(store, WithAchievements(engine))
+
+		server.ServeHTTP(httptest.NewRecorder(), newPostTeamWinRequest("Spicy"))
+
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin})
+		assertBadges(t, engine.Badges("Chilli"), []string{BadgeFirstWin})
+	})
+
+	t.Run("supports custom rules", func(t *testing.T) {
+		engine := NewAchievementEngine(Rule{"hat-trick", func(c WinContext) bool { return c.Streak == 3 }})
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(engine))
+
+		recordWins(server, "Pepper", "Pepper", "Pepper")
+
+		assertBadges(t, engine.Badges("Pepper"), []string{"hat-trick"})
+	})
+
+	t.Run("lists achievements in the league and per player", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithAchievements(NewAchievementEngine()))
+		recordWins(server, "Pepper")
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newLeagueRequest())
+		want := []Player{{Name: "Pepper", Wins: 1, Achievements: []string{BadgeFirstWin}}}
+		assertLeague(t, getLeagueFromResponse(t, response.Body), want)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/players/Pepper/achievements"))
+		assertResponseCode(t, response.Code, http.StatusOK)
+		var got []Achievement
+		json.NewDecoder(response.Body).Decode(&got)
+		if len(got) != 1 || got[0].Badge != BadgeFirstWin || got[0].AwardedAt.IsZero() {
+			t.Errorf("got achievements %+v", got)
+		}
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/players/Floyd/achievements"))
+		assertResponseBody(t, response.Body.String(), "[]\n")
+	})
+
+	t.Run("returns 501 when achievements are disabled", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/players/Pepper/achievements"))
+
+		assertResponseCode(t, response.Code, http.StatusNotImplemented)
+	})
+}
+
+type leagueCountingStore struct {
+	*InMemoryPlayerStore
+	leagues int
+}
+
+func (s *leagueCountingStore) GetLeague() []Player {
+	s.leagues++
+	return s.InMemoryPlayerStore.GetLeague()
+}
+
+func recordWins(server *PlayerServer, names ...string) {
+	for _, name := range names {
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(name))
+	}
+}
+
+func assertBadges(t *testing.T, got, want []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got badges %v want %v", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	batchPath        = "_batch"
+	maxBatchItems    = 1000
+	maxBatchItemWins = 1000
+)
+
+var ErrBatchUnsupported = errors.New("leader does not accept batches of wins")
+
+type WinIncrement struct {
+	Name string `json:"name"`
+	Wins int    `json:"wins"`
+}
+
+// BatchResult reports one item of a batch. Score is the player's total
+// after the batch was applied.
+type BatchResult struct {
+	Name  string `json:"name"`
+	Wins  int    `json:"wins"`
+	Score int    `json:"score,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// BatchRecorder is implemented by stores that can apply many wins as a
+// single change, so readers and crashes never see half a batch.
+type BatchRecorder interface {
+	RecordWins(batch []WinIncrement) error
+}
+
+// RecordWins applies the batch in one step when the store supports it,
+// and one win at a time otherwise.
+func RecordWins(store PlayerStore, batch []WinIncrement) error {
+	if recorder, ok := store.(BatchRecorder); ok {
+		return recorder.RecordWins(batch)
+	}
+	for _, inc := range batch {
+		for i := 0; i < inc.Wins; i++ {
+			store.RecordWin(inc.Name)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryPlayerStore) RecordWins(batch []WinIncrement) error {
+	batch, err := s.normalizeBatch(batch)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(batch))
+	for i, inc := range batch {
+		names[i] = inc.Name
+	}
+	unlock := s.lockShards(names...)
+	defer unlock()
+	now := time.Now().UTC()
+	for _, inc := range batch {
+		s.credit(s.shard(inc.Name), inc.Name, inc.Wins, now)
+	}
+	return nil
+}
+
+// RecordWins logs the whole batch as one record.
+func (s *WALPlayerStore) RecordWins(batch []WinIncrement) error {
+	batch, err := s.normalizeBatch(batch)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := walRecord{Op: "batch", Wins: batch}
+	if err := s.append(&rec); err != nil {
+		return err
+	}
+	s.apply(rec)
+	s.maybeSnapshot()
+	return nil
+}
+
+// RecordWins appends the whole batch as one event.
+func (s *EventSourcedPlayerStore) RecordWins(batch []WinIncrement) error {
+	batch, err := s.normalizeBatch(batch)
+	if err != nil {
+		return err
+	}
+	return s.record(Event{Type: EventTypeBatch, Wins: batch})
+}
+
+// batchWinners lists each name in the batch once per win, for
+// advanceStreaks.
+func batchWinners(batch []WinIncrement) []string {
+	var names []string
+	for _, inc := range batch {
+		for i := 0; i < inc.Wins; i++ {
+			names = append(names, inc.Name)
+		}
+	}
+	return names
+}
+
+func (l *ReplicationLeader) RecordWins(batch []WinIncrement) error {
+	batch, err := l.normalizeBatch(batch)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := RecordWins(l.store, batch); err != nil {
+		return err
+	}
+	for _, inc := range batch {
+		l.appendEvent(ReplicationEvent{Name: inc.Name, Wins: inc.Wins})
+	}
+	return nil
+}
+
+// batchHandler checks every item before applying any, so a bad item
+// rejects the whole batch.
+func (p *PlayerServer) batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var batch []WinIncrement
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&batch); err != nil {
+		http.Error(w, "invalid batch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batch) == 0 || len(batch) > maxBatchItems {
+		http.Error(w, fmt.Sprintf("invalid batch: send between 1 and %d items", maxBatchItems), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]BatchResult, len(batch))
+	valid := true
+	for i, inc := range batch {
+		results[i] = BatchResult{Name: inc.Name, Wins: inc.Wins}
+		name, err := p.playerName(inc.Name)
+		switch {
+		case err != nil:
+			results[i].Error = err.Error()
+		case name == "" || strings.Contains(name, "/"):
+			results[i].Error = "name must be non-empty and must not contain '/'"
+		case inc.Wins < 1 || inc.Wins > maxBatchItemWins:
+			results[i].Error = fmt.Sprintf("wins must be between 1 and %d", maxBatchItemWins)
+		default:
+			batch[i].Name = name
+			results[i].Name = name
+			continue
+		}
+		valid = false
+	}
+	if !valid {
+		writeJSON(w, http.StatusBadRequest, results)
+		return
+	}
+
+	names := make([]string, len(batch))
+	for i, inc := range batch {
+		names[i] = inc.Name
+	}
+	rivals, before := p.rivalWins(names...), p.scoresBefore(names...)
+	if err := RecordWins(p.store, batch); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.updatePodium(names...)
+	p.awardAchievements(before, rivals, names...)
+	p.publishWins(before, names...)
+
+	for i := range results {
+		results[i].Score = p.store.GetPlayerScore(results[i].Name)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
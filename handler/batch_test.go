@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatchWins(t *testing.T) {
+	t.Run("applies every increment and reports the new scores", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Pepper")
+		server := NewPlayerServer(store)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "Floyd", "wins": 3}]`))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertBatchResults(t, response.Body.String(), []BatchResult{
+			{Name: "Pepper", Wins: 2, Score: 3},
+			{Name: "Floyd", Wins: 3, Score: 3},
+		})
+		assertScoreEquals(t, len(store.WinHistory("Floyd")), 3)
+	})
+
+	t.Run("rejects the whole batch when one item is invalid", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "", "wins": 1}, {"name": "Floyd", "wins": 0}]`))
+
+		assertResponseCode(t, response.Code, http.StatusBadRequest)
+		var results []BatchResult
+		json.NewDecoder(response.Body).Decode(&results)
+		if len(results) != 3 || results[0].Error != "" || results[1].Error == "" || results[2].Error == "" {
+			t.Errorf("got results %+v", results)
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 0)
+	})
+
+	t.Run("rejects malformed and empty batches", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		for _, body := range []string{`[]`, `{"name": "Pepper"}`, `[{"name": "Pepper", "wins": 1, "extra": true}]`} {
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, newBatchRequest(body))
+			assertResponseCode(t, response.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("normalizes names with the server's policy", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store, WithNamePolicy(DefaultNamePolicy))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newBatchRequest(`[{"name": "Pepper", "wins": 1}, {"name": "pepper ", "wins": 1}]`))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertLeague(t, store.GetLeague(), []Player{{Name: "pepper", Wins: 2}})
+	})
+
+	t.Run("falls back to single wins for other stores", func(t *testing.T) {
+		store := &StubPlayerStore{scores: map[string]int{}}
+		server := NewPlayerServer(store)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "Floyd", "wins": 1}]`))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		if !reflect.DeepEqual(store.winCalls, []string{"Pepper", "Pepper", "Floyd"}) {
+			t.Errorf("got win calls %v", store.winCalls)
+		}
+	})
+
+	t.Run("logs a batch as one WAL record", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		server := NewPlayerServer(store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "Floyd", "wins": 1}]`))
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertLeague(t, sortedLeague(store.GetLeague()), []Player{{Name: "Floyd", Wins: 1}, {Name: "Pepper", Wins: 2}})
+		assertScoreEquals(t, int(store.seq), 1)
+	})
+
+	t.Run("appends a batch as one event", func(t *testing.T) {
+		events := NewInMemoryEventLog()
+		store := newTestEventStore(t, events)
+		server := NewPlayerServer(store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "Floyd", "wins": 1}]`))
+
+		logged, _ := events.Read(0, 10)
+		if len(logged) != 1 || logged[0].Type != EventTypeBatch {
+			t.Fatalf("got events %+v, want one batch", logged)
+		}
+		rebuilt := newTestEventStore(t, events)
+		assertLeague(t, sortedLeague(rebuilt.GetLeague()), []Player{{Name: "Floyd", Wins: 1}, {Name: "Pepper", Wins: 2}})
+		assertScoreEquals(t, rebuilt.Streak("Pepper"), 2)
+	})
+
+	t.Run("replicates a batch to followers", func(t *testing.T) {
+		leader := NewReplicationLeader(NewInMemoryPlayerStore())
+		assertNoStoreError(t, RecordWins(leader, []WinIncrement{{"Pepper", 2}, {"Floyd", 1}}))
+
+		_, events, _ := leader.EventsSince(leader.epoch, 0)
+		want := []ReplicationEvent{{Seq: 1, Name: "Pepper", Wins: 2}, {Seq: 2, Name: "Floyd", Wins: 1}}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("got events %+v want %+v", events, want)
+		}
+	})
+}
+
+func newBatchRequest(body string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, "/players/_batch", strings.NewReader(body))
+	return request
+}
+
+func assertBatchResults(t *testing.T, body string, want []BatchResult) {
+	t.Helper()
+	var got []BatchResult
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("could not parse %q, %v", body, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %+v want %+v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+// Command loadtest drives a server at -url, or an in-process one backed
+// by -store when no URL is given, and prints the report.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/quii/learn-go-with-tests/handler"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		log.Fatalf("load test failed %v", err)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	url := flags.String("url", "", "server to load; empty runs one in-process")
+	backend := flags.String("store", "memory", "in-process store: memory, wal or events")
+	mix := flags.String("mix", "6,3,1", "weights of score lookups, wins and league reads")
+	workers := flags.Int("workers", 8, "concurrent clients")
+	players := flags.Int("players", 100, "distinct player names")
+	requests := flags.Int("requests", 0, "stop after this many requests")
+	duration := flags.Duration("duration", 10*time.Second, "stop after this long")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	g := &handler.LoadGenerator{URL: *url, Workers: *workers, Players: *players, Requests: *requests, Duration: *duration}
+	var err error
+	if g.Mix, err = handler.ParseLoadMix(*mix); err != nil {
+		return err
+	}
+	if g.URL == "" {
+		dir, err := os.MkdirTemp("", "loadtest")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		store, err := openStore(*backend, dir)
+		if err != nil {
+			return err
+		}
+		if closer, ok := store.(io.Closer); ok {
+			defer closer.Close()
+		}
+		g.Handler = handler.NewPlayerServer(store)
+	}
+
+	report, err := g.Run(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	return nil
+}
+
+func openStore(backend, dir string) (handler.PlayerStore, error) {
+	switch backend {
+	case "memory":
+		return handler.NewInMemoryPlayerStore(), nil
+	case "wal":
+		return handler.NewWALPlayerStore(dir, handler.WALOptions{Sync: handler.SyncBatch})
+	case "events":
+		events, err := handler.NewFileEventLog(filepath.Join(dir, "events.log"))
+		if err != nil {
+			return nil, err
+		}
+		return handler.NewEventSourcedPlayerStore(events)
+	default:
+		return nil, fmt.Errorf("unknown store %q", backend)
+	}
+}
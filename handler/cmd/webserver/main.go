@@ -0,0 +1,71 @@
+// Command webserver serves the league over HTTP until it is interrupted.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/quii/learn-go-with-tests/handler"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	addr := flag.String("addr", ":5000", "address to listen on")
+	var tlsOpts handler.TLSOptions
+	flag.StringVar(&tlsOpts.CertFile, "tls-cert", "", "TLS certificate file")
+	flag.StringVar(&tlsOpts.KeyFile, "tls-key", "", "TLS key file")
+	flag.BoolVar(&tlsOpts.SelfSigned, "tls-self-signed", false, "generate a development certificate if there isn't one")
+	flag.StringVar(&tlsOpts.ClientCAFile, "tls-client-ca", "", "require client certificates signed by these CAs")
+	corsOrigins := flag.String("cors-origins", "", "comma-separated origins allowed to call the API from a browser")
+	flag.Parse()
+	options := []handler.ServerOption{handler.WithNamePolicy(handler.DefaultNamePolicy)}
+	if *corsOrigins != "" {
+		origins, err := parseOrigins(*corsOrigins)
+		if err != nil {
+			log.Fatalf("could not read -cors-origins %v", err)
+		}
+		options = append(options, handler.WithCORS(handler.CORSOptions{AllowedOrigins: origins}))
+	}
+	server := handler.NewServer(*addr, handler.NewPlayerServer(handler.NewInMemoryPlayerStore(), options...))
+	if tlsOpts.Enabled() {
+		config, err := handler.NewTLSConfig(tlsOpts)
+		if err != nil {
+			log.Fatalf("could not set up TLS %v", err)
+		}
+		server.UseTLS(config)
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Fatalf("could not listen on %s %v", *addr, err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("could not shut down cleanly %v", err)
+	}
+}
+
+// parseOrigins splits a comma-separated list of origins, ignoring the
+// spaces around each one.
+func parseOrigins(list string) ([]string, error) {
+	origins := strings.Split(list, ",")
+	for i, origin := range origins {
+		origins[i] = strings.TrimSpace(origin)
+		if origins[i] == "" {
+			return nil, fmt.Errorf("origin %d of %q is empty", i+1, list)
+		}
+	}
+	return origins, nil
+}
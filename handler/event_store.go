@@ -0,0 +1,524 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	EventTypeWin            = "win.recorded"
+	EventTypeBatch          = "wins.recorded"
+	EventTypeMerge          = "player.merged"
+	EventTypeProfileSaved   = "profile.saved"
+	EventTypeProfileDeleted = "profile.deleted"
+	EventTypeTeamSaved      = "team.saved"
+	EventTypeTeamWin        = "team.won"
+	EventTypeAchievement    = "achievement.awarded"
+
+	defaultEventPage = 100
+	maxEventPage     = 1000
+)
+
+type Event struct {
+	Offset  int64    `json:"offset"`
+	Type    string   `json:"type"`
+	Player  string   `json:"player"`
+	Into    string   `json:"into,omitempty"`
+	Profile *Profile `json:"profile,omitempty"`
+	Team    *Team    `json:"team,omitempty"`
+
+	Wins        []WinIncrement `json:"wins,omitempty"`
+	Achievement *Achievement   `json:"achievement,omitempty"`
+	Time        time.Time      `json:"time"`
+}
+
+// EventLog is an append-only sequence of events. Append assigns the
+// event's offset.
+type EventLog interface {
+	Append(e Event) (Event, error)
+	Read(from int64, limit int) ([]Event, error)
+}
+
+// EventReader is implemented by stores that can replay their events.
+type EventReader interface {
+	Events(from int64, limit int) ([]Event, error)
+}
+
+type InMemoryEventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewInMemoryEventLog() *InMemoryEventLog {
+	return &InMemoryEventLog{}
+}
+
+func (l *InMemoryEventLog) Append(e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Offset = int64(len(l.events))
+	l.events = append(l.events, e)
+	return e, nil
+}
+
+func (l *InMemoryEventLog) Read(from int64, limit int) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return readPage(l.events, from, limit), nil
+}
+
+// FileEventLog keeps events as JSON lines in a file, fsyncing each one
+// before Append returns. An append that fails is cut back off the file;
+// if even that fails, the log refuses every later append.
+type FileEventLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	events []Event
+	size   int64
+	err    error
+}
+
+func NewFileEventLog(path string) (*FileEventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &FileEventLog{file: f}
+	if err := l.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileEventLog) Append(e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return e, l.err
+	}
+	e.Offset = int64(len(l.events))
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	line = append(line, '\n')
+	if err := l.write(line); err != nil {
+		// Cut off whatever part of the line reached the file, so the
+		// next append doesn't land after a fragment.
+		if truncErr := l.file.Truncate(l.size); truncErr != nil {
+			l.err = err
+		}
+		return e, err
+	}
+	l.size += int64(len(line))
+	l.events = append(l.events, e)
+	return e, nil
+}
+
+func (l *FileEventLog) write(line []byte) error {
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+func (l *FileEventLog) Read(from int64, limit int) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return readPage(l.events, from, limit), nil
+}
+
+func (l *FileEventLog) Close() error {
+	return l.file.Close()
+}
+
+func (l *FileEventLog) load() error {
+	r := bufio.NewReader(l.file)
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// A line without its newline is an append that never
+				// finished.
+				log.Printf("discarding torn event at offset %d of %s", good, l.file.Name())
+				if err := l.file.Truncate(good); err != nil {
+					return err
+				}
+			}
+			l.size = good
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var e Event
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			return err
+		}
+		if e.Offset != int64(len(l.events)) {
+			return errors.New("event log offsets are out of sequence")
+		}
+		l.events = append(l.events, e)
+		good += int64(len(line))
+	}
+}
+
+func readPage(events []Event, from int64, limit int) []Event {
+	if from < 0 {
+		from = 0
+	}
+	if from >= int64(len(events)) {
+		return []Event{}
+	}
+	end := int64(len(events))
+	if limit > 0 && from+int64(limit) < end {
+		end = from + int64(limit)
+	}
+	return append([]Event(nil), events[from:end]...)
+}
+
+// EventSourcedPlayerStore records every win as an event and answers
+// queries from projections built by replaying the log.
+type EventSourcedPlayerStore struct {
+	log EventLog
+	enforcedNames
+
+	mu           sync.RWMutex
+	scores       map[string]int
+	history      map[string][]time.Time
+	profiles     map[string]Profile
+	teams        map[string]Team
+	achievements map[string][]Achievement
+	streaks      map[string]int
+	index        *NameIndex
+	next         int64
+}
+
+func NewEventSourcedPlayerStore(events EventLog) (*EventSourcedPlayerStore, error) {
+	s := &EventSourcedPlayerStore{log: events}
+	if err := s.Rebuild(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *EventSourcedPlayerStore) GetPlayerScore(name string) int {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scores[name]
+}
+
+func (s *EventSourcedPlayerStore) RecordWin(name string) {
+	normalized, err := s.normalize(name)
+	if err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	name = normalized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.log.Append(Event{Type: EventTypeWin, Player: name, Time: time.Now().UTC()})
+	if err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	s.apply(e)
+}
+
+func (s *EventSourcedPlayerStore) GetLeague() []Player {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	players := make([]Player, 0, len(s.scores))
+	for k, v := range s.scores {
+		players = append(players, Player{Name: k, Wins: v})
+	}
+	return players
+}
+
+func (s *EventSourcedPlayerStore) WinHistory(name string) []time.Time {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]time.Time(nil), s.history[name]...)
+}
+
+func (s *EventSourcedPlayerStore) MergePlayer(from, into string) error {
+	into, err := s.normalize(into)
+	if err != nil {
+		return err
+	}
+	return s.record(Event{Type: EventTypeMerge, Player: from, Into: into})
+}
+
+func (s *EventSourcedPlayerStore) record(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendLocked(e)
+}
+
+func (s *EventSourcedPlayerStore) appendLocked(e Event) error {
+	e.Time = time.Now().UTC()
+	e, err := s.log.Append(e)
+	if err != nil {
+		return err
+	}
+	s.apply(e)
+	return nil
+}
+
+func (s *EventSourcedPlayerStore) GetProfile(name string) (Profile, error) {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profile, ok := s.profiles[name]
+	if !ok {
+		return Profile{}, ErrProfileNotFound
+	}
+	return profile.clone(), nil
+}
+
+func (s *EventSourcedPlayerStore) SaveProfile(name string, profile Profile) error {
+	name, err := s.normalize(name)
+	if err != nil {
+		return err
+	}
+	profile = profile.clone()
+	return s.record(Event{Type: EventTypeProfileSaved, Player: name, Profile: &profile})
+}
+
+func (s *EventSourcedPlayerStore) DeleteProfile(name string) error {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	return s.appendLocked(Event{Type: EventTypeProfileDeleted, Player: name})
+}
+
+func (s *EventSourcedPlayerStore) GetTeam(name string) (Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	team, ok := s.teams[name]
+	if !ok {
+		return Team{}, ErrTeamNotFound
+	}
+	return team.clone(), nil
+}
+
+func (s *EventSourcedPlayerStore) GetTeams() ([]Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	teams := make([]Team, 0, len(s.teams))
+	for _, team := range s.teams {
+		teams = append(teams, team.clone())
+	}
+	return teams, nil
+}
+
+func (s *EventSourcedPlayerStore) SaveTeam(team Team) error {
+	members, err := s.normalizeAll(team.Members)
+	if err != nil {
+		return err
+	}
+	team = team.clone()
+	team.Members = members
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team.Wins = s.teams[team.Name].Wins
+	return s.appendLocked(Event{Type: EventTypeTeamSaved, Team: &team})
+}
+
+// RecordTeamWin appends one event for the team and all its members, so
+// a replay can't credit only some of them.
+func (s *EventSourcedPlayerStore) RecordTeamWin(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.teams[name]; !ok {
+		return ErrTeamNotFound
+	}
+	return s.appendLocked(Event{Type: EventTypeTeamWin, Player: name})
+}
+
+func (s *EventSourcedPlayerStore) Achievements(name string) []Achievement {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Achievement(nil), s.achievements[name]...)
+}
+
+// Award appends an event unless the player already holds the badge, and
+// reports whether it was new.
+func (s *EventSourcedPlayerStore) Award(name string, a Achievement) bool {
+	name, err := s.normalize(name)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holdsBadge(s.achievements[name], a.Badge) {
+		return false
+	}
+	if err := s.appendLocked(Event{Type: EventTypeAchievement, Player: name, Achievement: &a}); err != nil {
+		log.Printf("could not award %q to %q: %v", a.Badge, name, err)
+		return false
+	}
+	return true
+}
+
+func (s *EventSourcedPlayerStore) Streak(name string) int {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.streaks[name]
+}
+
+func (s *EventSourcedPlayerStore) SearchPlayers(query string) []NameMatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.Search(query)
+}
+
+func (s *EventSourcedPlayerStore) Events(from int64, limit int) ([]Event, error) {
+	return s.log.Read(from, limit)
+}
+
+// Rebuild throws the projections away and replays every event.
+func (s *EventSourcedPlayerStore) Rebuild() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scores = make(map[string]int)
+	s.history = make(map[string][]time.Time)
+	s.profiles = make(map[string]Profile)
+	s.teams = make(map[string]Team)
+	s.achievements = make(map[string][]Achievement)
+	s.streaks = make(map[string]int)
+	s.index = NewNameIndex()
+	s.next = 0
+	for {
+		events, err := s.log.Read(s.next, maxEventPage)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, e := range events {
+			s.apply(e)
+		}
+	}
+}
+
+func (s *EventSourcedPlayerStore) Close() error {
+	if closer, ok := s.log.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (s *EventSourcedPlayerStore) apply(e Event) {
+	switch e.Type {
+	case EventTypeWin:
+		s.credit(e.Player, e.Time)
+		advanceStreaks(s.streaks, e.Player)
+	case EventTypeBatch:
+		for _, inc := range e.Wins {
+			for i := 0; i < inc.Wins; i++ {
+				s.credit(inc.Name, e.Time)
+			}
+		}
+		advanceStreaks(s.streaks, batchWinners(e.Wins)...)
+	case EventTypeProfileSaved:
+		if e.Profile != nil {
+			s.profiles[e.Player] = *e.Profile
+		}
+	case EventTypeProfileDeleted:
+		delete(s.profiles, e.Player)
+	case EventTypeTeamSaved:
+		if e.Team != nil {
+			s.teams[e.Team.Name] = *e.Team
+		}
+	case EventTypeTeamWin:
+		team, ok := s.teams[e.Player]
+		if !ok {
+			break
+		}
+		team.Wins++
+		s.teams[e.Player] = team
+		for _, m := range team.Members {
+			s.credit(m, e.Time)
+		}
+		advanceStreaks(s.streaks, team.Members...)
+	case EventTypeAchievement:
+		if e.Achievement != nil {
+			s.achievements[e.Player] = append(s.achievements[e.Player], *e.Achievement)
+		}
+	case EventTypeMerge:
+		mergePlayerState(e.Player, e.Into, s.scores, s.history, s.profiles, s.teams, s.achievements, s.streaks)
+		s.index.Remove(e.Player)
+		if s.scores[e.Into] > 0 {
+			s.index.Add(e.Into)
+		}
+	}
+	s.next = e.Offset + 1
+}
+
+func (s *EventSourcedPlayerStore) credit(name string, at time.Time) {
+	if s.scores[name] == 0 {
+		s.index.Add(name)
+	}
+	s.scores[name]++
+	s.history[name] = appendHistory(s.history[name], at)
+}
+
+type eventPage struct {
+	Events []Event `json:"events"`
+	Next   int64   `json:"next"`
+}
+
+func (p *PlayerServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	reader, ok := p.store.(EventReader)
+	if !ok {
+		http.Error(w, "player store does not keep events", http.StatusNotImplemented)
+		return
+	}
+
+	from, limit := int64(0), defaultEventPage
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = strconv.ParseInt(v, 10, 64); err != nil || from < 0 {
+			http.Error(w, "from must be a non-negative offset", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxEventPage {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxEventPage), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := reader.Events(from, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	next := from
+	if len(events) > 0 {
+		next = events[len(events)-1].Offset + 1
+	}
+	writeJSON(w, http.StatusOK, eventPage{Events: events, Next: next})
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestEventSourcedPlayerStore(t *testing.T) {
+	t.Run("projects scores from recorded events", func(t *testing.T) {
+		store := newTestEventStore(t, NewInMemoryEventLog())
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		store.RecordWin("Floyd")
+
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+		assertScoreEquals(t, store.GetPlayerScore("Floyd"), 1)
+		if got := len(store.WinHistory("Pepper")); got != 2 {
+			t.Errorf("got %d history entries want 2", got)
+		}
+	})
+
+	t.Run("rebuilds the same projections from the log", func(t *testing.T) {
+		events := NewInMemoryEventLog()
+		store := newTestEventStore(t, events)
+		store.RecordWin("Pepper")
+		store.RecordWin("Floyd")
+
+		rebuilt := newTestEventStore(t, events)
+		assertLeague(t, sortedLeague(rebuilt.GetLeague()), sortedLeague(store.GetLeague()))
+
+		assertNoStoreError(t, store.Rebuild())
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("assigns consecutive offsets", func(t *testing.T) {
+		store := newTestEventStore(t, NewInMemoryEventLog())
+		for i := 0; i < 3; i++ {
+			store.RecordWin("Pepper")
+		}
+
+		events, err := store.Events(1, 10)
+		assertNoStoreError(t, err)
+		if len(events) != 2 || events[0].Offset != 1 || events[1].Offset != 2 {
+			t.Errorf("got events %+v", events)
+		}
+	})
+
+	t.Run("persists events to a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.log")
+		events := newTestFileEventLog(t, path)
+		store := newTestEventStore(t, events)
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		assertNoStoreError(t, store.Close())
+
+		store = newTestEventStore(t, newTestFileEventLog(t, path))
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("drops a torn final event", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.log")
+		store := newTestEventStore(t, newTestFileEventLog(t, path))
+		store.RecordWin("Pepper")
+		assertNoStoreError(t, store.Close())
+
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		f.WriteString(`{"offset":1,"type":"win.rec`)
+		f.Close()
+
+		store = newTestEventStore(t, newTestFileEventLog(t, path))
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+		store.RecordWin("Pepper")
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("refuses to append after a failure it can't undo", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.log")
+		events := newTestFileEventLog(t, path)
+		_, err := events.Append(Event{Type: EventTypeWin, Player: "Pepper"})
+		assertNoStoreError(t, err)
+
+		file := events.file
+		events.file, _ = os.Open(path)
+		if _, err := events.Append(Event{Type: EventTypeWin, Player: "Pepper"}); err == nil {
+			t.Fatal("expected an append to a read-only file to fail")
+		}
+		events.file.Close()
+		events.file = file
+		if _, err := events.Append(Event{Type: EventTypeWin, Player: "Pepper"}); err == nil {
+			t.Error("expected the log to refuse appends after a failure")
+		}
+		assertNoStoreError(t, events.Close())
+
+		store := newTestEventStore(t, newTestFileEventLog(t, path))
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("replays profiles", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.log")
+		profile := Profile{DisplayName: "Pep", Tags: []string{"lefty"}, JoinedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+		store := newTestEventStore(t, newTestFileEventLog(t, path))
+		assertNoStoreError(t, store.SaveProfile("Pepper", profile))
+		assertNoStoreError(t, store.SaveProfile("Floyd", Profile{DisplayName: "Floyd"}))
+		assertNoStoreError(t, store.DeleteProfile("Floyd"))
+		assertNoStoreError(t, store.Close())
+
+		store = newTestEventStore(t, newTestFileEventLog(t, path))
+		defer store.Close()
+		got, err := store.GetProfile("Pepper")
+		assertNoStoreError(t, err)
+		assertProfile(t, got, profile)
+		if _, err := store.GetProfile("Floyd"); err != ErrProfileNotFound {
+			t.Errorf("got error %v, want %v", err, ErrProfileNotFound)
+		}
+	})
+
+	t.Run("replays teams and team wins", func(t *testing.T) {
+		events := NewInMemoryEventLog()
+		store := newTestEventStore(t, events)
+		assertNoStoreError(t, store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}}))
+		assertNoStoreError(t, store.RecordTeamWin("Spicy"))
+		assertNoStoreError(t, store.RecordTeamWin("Spicy"))
+		if err := store.RecordTeamWin("Mild"); err != ErrTeamNotFound {
+			t.Errorf("got error %v, want %v", err, ErrTeamNotFound)
+		}
+
+		rebuilt := newTestEventStore(t, events)
+		team, err := rebuilt.GetTeam("Spicy")
+		assertNoStoreError(t, err)
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}, Wins: 2})
+		assertScoreEquals(t, rebuilt.GetPlayerScore("Pepper"), 2)
+		assertScoreEquals(t, rebuilt.GetPlayerScore("Chilli"), 2)
+	})
+
+	t.Run("replays badges and streaks", func(t *testing.T) {
+		events := NewInMemoryEventLog()
+		server := NewPlayerServer(newTestEventStore(t, events), WithAchievements(NewAchievementEngine()))
+		recordWins(server, "Floyd", "Pepper", "Pepper", "Pepper")
+
+		engine := NewAchievementEngine()
+		server = NewPlayerServer(newTestEventStore(t, events), WithAchievements(engine))
+		assertBadges(t, engine.Badges("Floyd"), []string{BadgeFirstWin})
+		for i := 0; i < 7; i++ {
+			recordWins(server, "Pepper")
+		}
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeBeatTheLeader, BadgeWinStreak})
+	})
+
+	t.Run("merges profiles and team members", func(t *testing.T) {
+		store := newTestEventStore(t, NewInMemoryEventLog())
+		assertNoStoreError(t, store.SaveProfile("pepper", Profile{DisplayName: "Pep"}))
+		assertNoStoreError(t, store.SaveTeam(Team{Name: "Spicy", Members: []string{"pepper", "Chilli"}}))
+
+		assertNoStoreError(t, store.MergePlayer("pepper", "Pepper"))
+
+		if _, err := store.GetProfile("Pepper"); err != nil {
+			t.Errorf("profile didn't move: %v", err)
+		}
+		team, _ := store.GetTeam("Spicy")
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}})
+	})
+}
+
+func TestEventsEndpoint(t *testing.T) {
+	store := newTestEventStore(t, NewInMemoryEventLog())
+	server := NewPlayerServer(store)
+	for _, name := range []string{"Pepper", "Floyd", "Pepper"} {
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(name))
+	}
+
+	t.Run("replays events from an offset", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/events?from=1&limit=1"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		var page eventPage
+		json.NewDecoder(response.Body).Decode(&page)
+		if len(page.Events) != 1 || page.Events[0].Player != "Floyd" || page.Next != 2 {
+			t.Errorf("got page %+v", page)
+		}
+	})
+
+	t.Run("returns an empty page past the end", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/events?from=10"))
+
+		var page eventPage
+		json.NewDecoder(response.Body).Decode(&page)
+		if len(page.Events) != 0 || page.Next != 10 {
+			t.Errorf("got page %+v", page)
+		}
+	})
+
+	t.Run("rejects a bad offset", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/events?from=-1"))
+		assertResponseCode(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("returns 501 for stores without events", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		NewPlayerServer(NewInMemoryPlayerStore()).ServeHTTP(response, newUIRequest("/events"))
+		assertResponseCode(t, response.Code, http.StatusNotImplemented)
+	})
+}
+
+func newTestEventStore(t *testing.T, events EventLog) *EventSourcedPlayerStore {
+	t.Helper()
+	store, err := NewEventSourcedPlayerStore(events)
+	if err != nil {
+		t.Fatalf("could not build store, %v", err)
+	}
+	return store
+}
+
+func newTestFileEventLog(t *testing.T, path string) *FileEventLog {
+	t.Helper()
+	events, err := NewFileEventLog(path)
+	if err != nil {
+		t.Fatalf("could not open event log, %v", err)
+	}
+	return events
+}
+
+func sortedLeague(league []Player) []Player {
+	sort.Slice(league, func(i, j int) bool { return league[i].Name < league[j].Name })
+	return league
+}
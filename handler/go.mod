@@ -0,0 +1,5 @@
+module github.com/quii/learn-go-with-tests/handler
+
+go 1.24
+
+require golang.org/x/text v0.25.0
@@ -3,8 +3,11 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
 type PlayerStore interface {
@@ -13,62 +16,370 @@ type PlayerStore interface {
 	GetLeague() []Player
 }
 
+// playerShards is how many locks player records are split across, so
+// writers for different players rarely wait on each other.
+const playerShards = 32
+
+type playerShard struct {
+	sync.RWMutex
+	scores  map[string]int
+	history map[string][]time.Time
+}
+
+// InMemoryPlayerStore spreads players over sharded read-write locks.
+// Profiles, teams and badges change rarely and share one lock of their
+// own.
+// Anything touching several players locks their shards in index order.
 type InMemoryPlayerStore struct {
-	sync.Mutex
-	store map[string]int
+	shards [playerShards]playerShard
+	enforcedNames
+
+	mu           sync.RWMutex
+	profiles     map[string]Profile
+	teams        map[string]Team
+	achievements map[string][]Achievement
+
+	index *NameIndex
 }
 
 func NewInMemoryPlayerStore() *InMemoryPlayerStore {
-	return &InMemoryPlayerStore{
-		store: make(map[string]int),
+	s := &InMemoryPlayerStore{
+		profiles:     make(map[string]Profile),
+		teams:        make(map[string]Team),
+		achievements: make(map[string][]Achievement),
+		index:        NewNameIndex(),
+	}
+	for i := range s.shards {
+		s.shards[i].scores = make(map[string]int)
+		s.shards[i].history = make(map[string][]time.Time)
+	}
+	return s
+}
+
+// shardIndex hashes name with FNV-1a.
+func shardIndex(name string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return int(h % playerShards)
+}
+
+func (s *InMemoryPlayerStore) shard(name string) *playerShard {
+	return &s.shards[shardIndex(name)]
+}
+
+// lockShards write-locks the shards holding names and returns the
+// function that unlocks them.
+func (s *InMemoryPlayerStore) lockShards(names ...string) func() {
+	var held [playerShards]bool
+	for _, name := range names {
+		held[shardIndex(name)] = true
+	}
+	for i := range s.shards {
+		if held[i] {
+			s.shards[i].Lock()
+		}
+	}
+	return func() {
+		for i := range s.shards {
+			if held[i] {
+				s.shards[i].Unlock()
+			}
+		}
 	}
 }
 
 func (s *InMemoryPlayerStore) GetPlayerScore(name string) int {
-	s.Lock()
-	defer s.Unlock()
-	return s.store[name]
+	name = s.lookup(name)
+	shard := s.shard(name)
+	shard.RLock()
+	defer shard.RUnlock()
+	return shard.scores[name]
 }
 
 func (s *InMemoryPlayerStore) RecordWin(name string) {
-	s.Lock()
-	defer s.Unlock()
-	old, ok := s.store[name]
-	if !ok {
-		s.store[name] = 1
+	normalized, err := s.normalize(name)
+	if err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
 		return
 	}
-	s.store[name] = old + 1
+	name = normalized
+	shard := s.shard(name)
+	shard.Lock()
+	defer shard.Unlock()
+	s.credit(shard, name, 1, time.Now().UTC())
 }
 
+// credit adds wins to a player whose shard the caller holds, indexing
+// the name the first time they win.
+func (s *InMemoryPlayerStore) credit(shard *playerShard, name string, wins int, at time.Time) {
+	if shard.scores[name] == 0 {
+		s.index.Add(name)
+	}
+	shard.scores[name] += wins
+	for i := 0; i < wins; i++ {
+		shard.history[name] = appendHistory(shard.history[name], at)
+	}
+}
+
+// GetLeague holds every shard's read lock at once, so the league is a
+// snapshot from a single moment even while wins are being recorded.
 func (s *InMemoryPlayerStore) GetLeague() []Player {
-	players := make([]Player, 0)
-	for k, v := range s.store {
-		players = append(players, Player{Name: k, Wins: v})
+	for i := range s.shards {
+		s.shards[i].RLock()
+	}
+	defer func() {
+		for i := range s.shards {
+			s.shards[i].RUnlock()
+		}
+	}()
+
+	n := 0
+	for i := range s.shards {
+		n += len(s.shards[i].scores)
+	}
+	players := make([]Player, 0, n)
+	for i := range s.shards {
+		for k, v := range s.shards[i].scores {
+			players = append(players, Player{Name: k, Wins: v})
+		}
 	}
 	return players
 }
 
+func (s *InMemoryPlayerStore) GetProfile(name string) (Profile, error) {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profile, ok := s.profiles[name]
+	if !ok {
+		return Profile{}, ErrProfileNotFound
+	}
+	return profile.clone(), nil
+}
+
+func (s *InMemoryPlayerStore) SaveProfile(name string, profile Profile) error {
+	name, err := s.normalize(name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[name] = profile.clone()
+	return nil
+}
+
+func (s *InMemoryPlayerStore) DeleteProfile(name string) error {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+func (s *InMemoryPlayerStore) GetTeam(name string) (Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	team, ok := s.teams[name]
+	if !ok {
+		return Team{}, ErrTeamNotFound
+	}
+	return team.clone(), nil
+}
+
+func (s *InMemoryPlayerStore) GetTeams() ([]Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	teams := make([]Team, 0, len(s.teams))
+	for _, team := range s.teams {
+		teams = append(teams, team.clone())
+	}
+	return teams, nil
+}
+
+func (s *InMemoryPlayerStore) SaveTeam(team Team) error {
+	members, err := s.normalizeAll(team.Members)
+	if err != nil {
+		return err
+	}
+	team.Members = members
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team.Wins = s.teams[team.Name].Wins
+	s.teams[team.Name] = team.clone()
+	return nil
+}
+
+// RecordTeamWin holds every member's shard while crediting them, so no
+// reader sees only part of the team's win.
+func (s *InMemoryPlayerStore) RecordTeamWin(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team, ok := s.teams[name]
+	if !ok {
+		return ErrTeamNotFound
+	}
+	team.Wins++
+	s.teams[name] = team
+
+	unlock := s.lockShards(team.Members...)
+	defer unlock()
+	now := time.Now().UTC()
+	for _, m := range team.Members {
+		s.credit(s.shard(m), m, 1, now)
+	}
+	return nil
+}
+
+// MergePlayer folds from's wins, history, profile and team places into
+// into's.
+func (s *InMemoryPlayerStore) MergePlayer(from, into string) error {
+	into, err := s.normalize(into)
+	if err != nil {
+		return err
+	}
+	if from == into {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock := s.lockShards(from, into)
+	defer unlock()
+
+	src, dst := s.shard(from), s.shard(into)
+	scores := map[string]int{into: dst.scores[into]}
+	if wins, ok := src.scores[from]; ok {
+		scores[from] = wins
+	}
+	history := map[string][]time.Time{from: src.history[from], into: dst.history[into]}
+	mergePlayerState(from, into, scores, history, s.profiles, s.teams, s.achievements, nil)
+
+	delete(src.scores, from)
+	delete(src.history, from)
+	if scores[into] > 0 {
+		dst.scores[into] = scores[into]
+	}
+	if len(history[into]) > 0 {
+		dst.history[into] = history[into]
+	}
+	s.index.Remove(from)
+	if dst.scores[into] > 0 {
+		s.index.Add(into)
+	}
+	return nil
+}
+
+func (s *InMemoryPlayerStore) Achievements(name string) []Achievement {
+	name = s.lookup(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Achievement(nil), s.achievements[name]...)
+}
+
+// Award records the achievement unless the player already holds the
+// badge, and reports whether it was new.
+func (s *InMemoryPlayerStore) Award(name string, a Achievement) bool {
+	name, err := s.normalize(name)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holdsBadge(s.achievements[name], a.Badge) {
+		return false
+	}
+	s.achievements[name] = append(s.achievements[name], a)
+	return true
+}
+
+func (s *InMemoryPlayerStore) SearchPlayers(query string) []NameMatch {
+	return s.index.Search(query)
+}
+
+func (s *InMemoryPlayerStore) WinHistory(name string) []time.Time {
+	name = s.lookup(name)
+	shard := s.shard(name)
+	shard.RLock()
+	defer shard.RUnlock()
+	return append([]time.Time(nil), shard.history[name]...)
+}
+
 type PlayerServer struct {
-	store PlayerStore
+	store        PlayerStore
+	tournaments  *TournamentRegistry
+	webhooks     *WebhookDispatcher
+	achievements *AchievementEngine
+	names        *NamePolicy
+	cors         *CORSOptions
+	podium       *podium
+	leaderMu     sync.Mutex
+	leaderName   string
+	stopping     chan struct{}
+	stopOnce     sync.Once
 	http.Handler
 }
 
-func NewPlayerServer(store PlayerStore) *PlayerServer {
+type ServerOption func(*PlayerServer)
+
+// WithWebhooks publishes league events to the dispatcher's subscribers
+// and serves /webhooks for managing them with the dispatcher's
+// AdminToken.
+func WithWebhooks(d *WebhookDispatcher) ServerOption {
+	return func(p *PlayerServer) {
+		p.webhooks = d
+	}
+}
+
+func NewPlayerServer(store PlayerStore, options ...ServerOption) *PlayerServer {
 	p := new(PlayerServer)
 	p.store = store
+	p.tournaments = NewTournamentRegistry()
+	p.stopping = make(chan struct{})
+	for _, option := range options {
+		option(p)
+	}
+	if p.webhooks != nil || p.achievements != nil {
+		p.podium = newPodium(store.GetLeague())
+		p.leaderName = p.podium.leader().Name
+	}
 
 	router := http.NewServeMux()
 	router.Handle("/league", http.HandlerFunc(p.leagueHandler))
 
+	router.Handle("/players", http.HandlerFunc(p.searchHandler))
 	router.Handle("/players/", http.HandlerFunc(p.playerHandler))
-	p.Handler = router
+	router.Handle("/teams", http.HandlerFunc(p.teamsHandler))
+	router.Handle("/teams/", http.HandlerFunc(p.teamsHandler))
+	router.Handle("/tournaments", http.HandlerFunc(p.tournamentsHandler))
+	router.Handle("/tournaments/", http.HandlerFunc(p.tournamentsHandler))
+	router.Handle("/webhooks", http.HandlerFunc(p.webhooksHandler))
+	router.Handle("/webhooks/", http.HandlerFunc(p.webhooksHandler))
+	router.Handle("/ui/", p.uiHandler())
+	router.Handle("/events", http.HandlerFunc(p.eventsHandler))
+	router.Handle("/healthz", http.HandlerFunc(p.healthzHandler))
+	router.Handle("/readyz", http.HandlerFunc(p.readyzHandler))
+	router.Handle(replicationPath, http.HandlerFunc(p.replicationHandler))
+	var handler http.Handler = router
+	if p.cors != nil {
+		handler = p.cors.wrap(handler)
+	}
+	p.Handler = securityHeaders(handler)
 	return p
 }
 
 func (p *PlayerServer) leagueHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
-	json.NewEncoder(w).Encode(p.getLeagueTable())
+	league := p.getLeagueTable()
+	if r.URL.Query().Get("profiles") == "true" {
+		league = p.withProfiles(league)
+	}
+	league = p.withAchievements(league)
+	json.NewEncoder(w).Encode(league)
 
 }
 
@@ -77,7 +388,29 @@ func (p *PlayerServer) getLeagueTable() []Player {
 }
 
 func (p *PlayerServer) playerHandler(w http.ResponseWriter, r *http.Request) {
-	player := r.URL.Path[len("/players/"):]
+	player, resource, _ := strings.Cut(r.URL.Path[len("/players/"):], "/")
+	if player == batchPath && resource == "" {
+		p.batchHandler(w, r)
+		return
+	}
+	player, err := p.playerName(player)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch resource {
+	case "":
+	case "profile":
+		p.profileHandler(w, r, player)
+		return
+	case "achievements":
+		p.achievementsHandler(w, r, player)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		p.showScore(w, player)
@@ -88,10 +421,18 @@ func (p *PlayerServer) playerHandler(w http.ResponseWriter, r *http.Request) {
 
 func (p *PlayerServer) processWin(w http.ResponseWriter, player string) {
 
-	p.store.RecordWin(player)
+	p.recordWin(player)
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (p *PlayerServer) recordWin(player string) {
+	rivals, before := p.rivalWins(player), p.scoresBefore(player)
+	p.store.RecordWin(player)
+	p.updatePodium(player)
+	p.awardAchievements(before, rivals, player)
+	p.publishWins(before, player)
+}
+
 func (p *PlayerServer) showScore(w http.ResponseWriter, player string) {
 	score := p.store.GetPlayerScore(player)
 
@@ -162,9 +162,9 @@ func TestLeague(t *testing.T) {
 
 	t.Run("it returns the league table as Json", func(t *testing.T) {
 		wantedLeague := []Player{
-			{"Cleo", 32},
-			{"Chris", 20},
-			{"Tiest", 14},
+			{Name: "Cleo", Wins: 32},
+			{Name: "Chris", Wins: 20},
+			{Name: "Tiest", Wins: 14},
 		}
 
 		store = &StubPlayerStore{nil, nil, wantedLeague}
@@ -208,7 +208,7 @@ func TestRecordingWinsAndRetrievingThemWithInMemoryStore(t *testing.T) {
 
 		got := getLeagueFromResponse(t, response.Body)
 		want := []Player{
-			{"Pepper", 3},
+			{Name: "Pepper", Wins: 3},
 		}
 		assertLeague(t, got, want)
 	})
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+var ErrDraining = errors.New("server is shutting down")
+
+// Pinger is implemented by stores that can check their backend is
+// reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func (p *PlayerServer) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "ok")
+}
+
+func (p *PlayerServer) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if err := p.ready(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "ready")
+}
+
+// drain fails readiness checks and ends long-lived streams so they don't
+// hold up a shutdown.
+func (p *PlayerServer) drain() {
+	p.stopOnce.Do(func() { close(p.stopping) })
+}
+
+func (p *PlayerServer) ready(ctx context.Context) error {
+	select {
+	case <-p.stopping:
+		return ErrDraining
+	default:
+	}
+	if pinger, ok := p.store.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Server runs a PlayerServer until it is shut down, then drains open
+// connections and closes the store.
+type Server struct {
+	HTTP   *http.Server
+	player *PlayerServer
+}
+
+func NewServer(addr string, player *PlayerServer) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              addr,
+			Handler:           player,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		player: player,
+	}
+}
+
+// ListenAndServe blocks until the server fails or Shutdown is called, in
+// which case it returns nil.
+func (s *Server) ListenAndServe() error {
+	if s.HTTP.TLSConfig != nil {
+		return ignoreServerClosed(s.HTTP.ListenAndServeTLS("", ""))
+	}
+	return ignoreServerClosed(s.HTTP.ListenAndServe())
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	if s.HTTP.TLSConfig != nil {
+		return ignoreServerClosed(s.HTTP.ServeTLS(l, "", ""))
+	}
+	return ignoreServerClosed(s.HTTP.Serve(l))
+}
+
+// Shutdown fails readiness checks, waits for in-flight requests and
+// webhook deliveries, and then closes the store if it is an io.Closer.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.player.drain()
+	err := s.HTTP.Shutdown(ctx)
+
+	if s.player.webhooks != nil {
+		done := make(chan struct{})
+		go func() {
+			s.player.webhooks.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+
+	if closer, ok := s.player.store.(io.Closer); ok {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
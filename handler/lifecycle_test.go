@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type pingingPlayerStore struct {
+	*InMemoryPlayerStore
+	pingErr error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *pingingPlayerStore) Ping(ctx context.Context) error {
+	return s.pingErr
+}
+
+func (s *pingingPlayerStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *pingingPlayerStore) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+type slowPlayerStore struct {
+	*pingingPlayerStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowPlayerStore) RecordWin(name string) {
+	close(s.started)
+	<-s.release
+	s.pingingPlayerStore.RecordWin(name)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Run("healthz is always ok", func(t *testing.T) {
+		server := NewPlayerServer(&pingingPlayerStore{InMemoryPlayerStore: NewInMemoryPlayerStore(), pingErr: errors.New("down")})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/healthz"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), "ok")
+	})
+
+	t.Run("readyz is ok when the store answers", func(t *testing.T) {
+		server := NewPlayerServer(&pingingPlayerStore{InMemoryPlayerStore: NewInMemoryPlayerStore()})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/readyz"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("readyz fails when the store is down", func(t *testing.T) {
+		server := NewPlayerServer(&pingingPlayerStore{InMemoryPlayerStore: NewInMemoryPlayerStore(), pingErr: errors.New("down")})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/readyz"))
+
+		assertResponseCode(t, response.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("readyz fails once the server starts draining", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		server.drain()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/readyz"))
+
+		assertResponseCode(t, response.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("follower is not ready without its leader", func(t *testing.T) {
+		leader := httptest.NewServer(NewPlayerServer(NewReplicationLeader(NewInMemoryPlayerStore())))
+		follower := NewReplicationFollower(leader.URL)
+		assertNoStoreError(t, follower.Ping(context.Background()))
+
+		leader.Close()
+		if err := follower.Ping(context.Background()); err == nil {
+			t.Error("expected an error once the leader is gone")
+		}
+	})
+}
+
+func TestServerShutdown(t *testing.T) {
+	t.Run("drains in-flight requests and closes the store", func(t *testing.T) {
+		store := &slowPlayerStore{
+			pingingPlayerStore: &pingingPlayerStore{InMemoryPlayerStore: NewInMemoryPlayerStore()},
+			started:            make(chan struct{}),
+			release:            make(chan struct{}),
+		}
+		server, url := startTestServer(t, NewPlayerServer(store))
+
+		result := make(chan int)
+		go func() {
+			res, err := http.Post(url+"/players/Pepper", "", nil)
+			if err != nil {
+				result <- 0
+				return
+			}
+			res.Body.Close()
+			result <- res.StatusCode
+		}()
+		<-store.started
+
+		shutdown := make(chan error)
+		go func() { shutdown <- server.Shutdown(context.Background()) }()
+
+		time.Sleep(10 * time.Millisecond)
+		if store.isClosed() {
+			t.Fatal("store was closed before the request finished")
+		}
+		close(store.release)
+
+		assertResponseCode(t, <-result, http.StatusAccepted)
+		assertNoStoreError(t, <-shutdown)
+		if !store.isClosed() {
+			t.Error("expected the store to be closed")
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("ends replication streams so shutdown doesn't hang", func(t *testing.T) {
+		server, url := startTestServer(t, NewPlayerServer(NewReplicationLeader(NewInMemoryPlayerStore())))
+
+		res, err := http.Get(url + replicationPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		assertNoStoreError(t, server.Shutdown(ctx))
+		io.Copy(io.Discard, res.Body)
+	})
+}
+
+func startTestServer(t *testing.T, player *PlayerServer) (*Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(listener.Addr().String(), player)
+	done := make(chan error)
+	go func() { done <- server.Serve(listener) }()
+	t.Cleanup(func() {
+		server.HTTP.Close()
+		if err := <-done; err != nil {
+			t.Errorf("server stopped with %v", err)
+		}
+	})
+	return server, "http://" + listener.Addr().String()
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpGetScore  = "get-score"
+	OpPostWin   = "post-win"
+	OpGetLeague = "get-league"
+)
+
+// LoadMix weights each kind of request. A mix of 6, 3, 1 sends six score
+// lookups and three wins for every league read.
+type LoadMix struct {
+	GetScore  int
+	PostWin   int
+	GetLeague int
+}
+
+var DefaultLoadMix = LoadMix{GetScore: 6, PostWin: 3, GetLeague: 1}
+
+// ParseLoadMix reads a mix written as "score,win,league" weights.
+func ParseLoadMix(s string) (LoadMix, error) {
+	var m LoadMix
+	if _, err := fmt.Sscanf(strings.ReplaceAll(s, ",", " "), "%d %d %d", &m.GetScore, &m.PostWin, &m.GetLeague); err != nil {
+		return m, fmt.Errorf("mix must be three weights like 6,3,1: %w", err)
+	}
+	if m.GetScore < 0 || m.PostWin < 0 || m.GetLeague < 0 || m.total() == 0 {
+		return m, errors.New("mix weights must be non-negative and not all zero")
+	}
+	return m, nil
+}
+
+func (m LoadMix) total() int {
+	return m.GetScore + m.PostWin + m.GetLeague
+}
+
+func (m LoadMix) pick(r *rand.Rand) string {
+	n := r.Intn(m.total())
+	switch {
+	case n < m.GetScore:
+		return OpGetScore
+	case n < m.GetScore+m.PostWin:
+		return OpPostWin
+	default:
+		return OpGetLeague
+	}
+}
+
+// LoadGenerator drives a PlayerServer with concurrent workers. It calls
+// Handler directly when set, otherwise it sends real requests to URL.
+// The run stops after Requests requests or once Duration has passed,
+// whichever comes first.
+type LoadGenerator struct {
+	Handler  http.Handler
+	URL      string
+	Client   *http.Client
+	Mix      LoadMix
+	Workers  int
+	Players  int
+	Requests int
+	Duration time.Duration
+}
+
+type OpStats struct {
+	Requests int
+	Errors   int
+}
+
+type LoadReport struct {
+	Requests      int
+	Errors        int
+	Elapsed       time.Duration
+	Throughput    float64
+	WinsPerSecond float64
+	P50           time.Duration
+	P90           time.Duration
+	P99           time.Duration
+	Ops           map[string]OpStats
+}
+
+func (r LoadReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests in %v, %d errors\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Errors)
+	fmt.Fprintf(&b, "%.0f req/s, %.0f wins/s\n", r.Throughput, r.WinsPerSecond)
+	fmt.Fprintf(&b, "latency p50 %v p90 %v p99 %v\n", r.P50, r.P90, r.P99)
+	for _, op := range []string{OpGetScore, OpPostWin, OpGetLeague} {
+		if s, ok := r.Ops[op]; ok {
+			fmt.Fprintf(&b, "  %-10s %8d requests %6d errors\n", op, s.Requests, s.Errors)
+		}
+	}
+	return b.String()
+}
+
+type loadResult struct {
+	op      string
+	latency time.Duration
+	failed  bool
+}
+
+func (g *LoadGenerator) Run(ctx context.Context) (LoadReport, error) {
+	if g.Handler == nil && g.URL == "" {
+		return LoadReport{}, errors.New("load generator needs a handler or a URL")
+	}
+	if g.Requests <= 0 && g.Duration <= 0 {
+		return LoadReport{}, errors.New("load generator needs a request count or a duration")
+	}
+	mix := g.Mix
+	if mix.total() == 0 {
+		mix = DefaultLoadMix
+	}
+	workers := g.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	players := g.Players
+	if players <= 0 {
+		players = 100
+	}
+	// The deadline only stops new requests, so ones in flight when it
+	// passes still finish and count.
+	stop, cancel := ctx, context.CancelFunc(func() {})
+	if g.Duration > 0 {
+		stop, cancel = context.WithTimeout(ctx, g.Duration)
+	}
+	defer cancel()
+
+	// Each worker takes a ticket before every request, so the total is
+	// exact however the work ends up spread.
+	tickets := make(chan struct{})
+	go func() {
+		defer close(tickets)
+		for i := 0; g.Requests <= 0 || i < g.Requests; i++ {
+			select {
+			case tickets <- struct{}{}:
+			case <-stop.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([][]loadResult, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+			for range tickets {
+				op := mix.pick(r)
+				player := fmt.Sprintf("player-%d", r.Intn(players))
+				began := time.Now()
+				failed := g.do(ctx, op, player)
+				results[w] = append(results[w], loadResult{op, time.Since(began), failed})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return summarize(results, time.Since(start)), nil
+}
+
+// do sends one request and reports whether it failed. Looking up a
+// player who hasn't won yet is a 404, which is a correct answer.
+func (g *LoadGenerator) do(ctx context.Context, op, player string) bool {
+	method, path := http.MethodGet, "/league"
+	switch op {
+	case OpGetScore:
+		path = "/players/" + player
+	case OpPostWin:
+		method, path = http.MethodPost, "/players/"+player
+	}
+
+	var status int
+	if g.Handler != nil {
+		req, err := http.NewRequestWithContext(ctx, method, path, nil)
+		if err != nil {
+			return true
+		}
+		res := &statusWriter{}
+		g.Handler.ServeHTTP(res, req)
+		status = res.Status()
+	} else {
+		req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(g.URL, "/")+path, nil)
+		if err != nil {
+			return true
+		}
+		client := g.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return true
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		status = res.StatusCode
+	}
+	return status >= 400 && !(op == OpGetScore && status == http.StatusNotFound)
+}
+
+// statusWriter is the ResponseWriter in-process requests are served
+// into. It keeps the status and throws the body away.
+type statusWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *statusWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
+
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func summarize(results [][]loadResult, elapsed time.Duration) LoadReport {
+	report := LoadReport{Elapsed: elapsed, Ops: make(map[string]OpStats)}
+	var latencies []time.Duration
+	wins := 0
+	for _, worker := range results {
+		for _, r := range worker {
+			stats := report.Ops[r.op]
+			stats.Requests++
+			report.Requests++
+			if r.failed {
+				stats.Errors++
+				report.Errors++
+			} else if r.op == OpPostWin {
+				wins++
+			}
+			report.Ops[r.op] = stats
+			latencies = append(latencies, r.latency)
+		}
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+		report.WinsPerSecond = float64(wins) / elapsed.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 50)
+	report.P90 = percentile(latencies, 90)
+	report.P99 = percentile(latencies, 99)
+	return report
+}
+
+// percentile uses the nearest-rank method on sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadGenerator(t *testing.T) {
+	t.Run("sends exactly the requested number of requests", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		g := &LoadGenerator{
+			Handler:  NewPlayerServer(store),
+			Mix:      LoadMix{PostWin: 1},
+			Workers:  4,
+			Players:  5,
+			Requests: 200,
+		}
+
+		report, err := g.Run(context.Background())
+
+		assertNoStoreError(t, err)
+		assertScoreEquals(t, report.Requests, 200)
+		assertScoreEquals(t, report.Errors, 0)
+		wins := 0
+		for _, player := range store.GetLeague() {
+			wins += player.Wins
+		}
+		assertScoreEquals(t, wins, 200)
+		if report.WinsPerSecond <= 0 || report.P50 > report.P99 {
+			t.Errorf("got report %+v", report)
+		}
+	})
+
+	t.Run("drives a server over HTTP until the duration passes", func(t *testing.T) {
+		server := httptest.NewServer(NewPlayerServer(NewInMemoryPlayerStore()))
+		defer server.Close()
+		g := &LoadGenerator{URL: server.URL, Workers: 2, Duration: 50 * time.Millisecond}
+
+		report, err := g.Run(context.Background())
+
+		assertNoStoreError(t, err)
+		assertScoreEquals(t, report.Errors, 0)
+		if report.Requests == 0 || report.Ops[OpGetScore].Requests == 0 {
+			t.Errorf("got report %+v", report)
+		}
+	})
+
+	t.Run("counts failed requests", func(t *testing.T) {
+		server := httptest.NewServer(NewPlayerServer(&StubPlayerStore{}))
+		server.Close()
+		g := &LoadGenerator{URL: server.URL, Requests: 3}
+
+		report, _ := g.Run(context.Background())
+
+		assertScoreEquals(t, report.Errors, 3)
+	})
+
+	t.Run("parses a mix", func(t *testing.T) {
+		mix, err := ParseLoadMix("1,2,0")
+		assertNoStoreError(t, err)
+		if mix != (LoadMix{GetScore: 1, PostWin: 2}) {
+			t.Errorf("got mix %+v", mix)
+		}
+		for _, bad := range []string{"1,2", "0,0,0", "-1,2,3", "a,b,c"} {
+			if _, err := ParseLoadMix(bad); err == nil {
+				t.Errorf("expected %q to be rejected", bad)
+			}
+		}
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i))
+	}
+	for p, want := range map[int]time.Duration{50: 50, 90: 90, 99: 99, 100: 100} {
+		if got := percentile(latencies, p); got != want {
+			t.Errorf("p%d got %v want %v", p, got, want)
+		}
+	}
+}
@@ -1,14 +0,0 @@
-package handler
-
-import (
-	"log"
-	"net/http"
-)
-
-func main() {
-	server := NewPlayerServer(NewInMemoryPlayerStore())
-	handler := http.HandlerFunc(server.ServeHTTP)
-	if err := http.ListenAndServe(":5000", handler); err != nil {
-		log.Fatalf("could not listen on port 5000 %v", err)
-	}
-}
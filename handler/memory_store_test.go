@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestInMemoryPlayerStoreConcurrency(t *testing.T) {
+	t.Run("counts every win recorded concurrently", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 250; i++ {
+					store.RecordWin(benchPlayer(i))
+					store.GetLeague()
+				}
+			}()
+		}
+		wg.Wait()
+
+		total := 0
+		for _, player := range store.GetLeague() {
+			total += player.Wins
+		}
+		assertScoreEquals(t, total, 2000)
+		assertScoreEquals(t, store.GetPlayerScore("player-7"), 8*3)
+		assertScoreEquals(t, len(store.WinHistory("player-7")), 8*3)
+	})
+
+	t.Run("never shows part of a team win", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		members := []string{"Pepper", "Chilli", "Paprika", "Cayenne", "Jalapeno"}
+		store.SaveTeam(Team{Name: "Spicy", Members: members})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 500; i++ {
+				store.RecordTeamWin("Spicy")
+			}
+		}()
+
+		for finished := false; !finished; {
+			select {
+			case <-done:
+				finished = true
+			default:
+			}
+			league := store.GetLeague()
+			for _, player := range league {
+				if player.Wins != league[0].Wins {
+					t.Fatalf("league shows a partial team win: %+v", league)
+				}
+			}
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Jalapeno"), 500)
+	})
+
+	t.Run("spreads players across shards", func(t *testing.T) {
+		used := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			used[shardIndex(benchPlayer(i))] = true
+		}
+		if len(used) < playerShards/2 {
+			t.Errorf("100 players only used %d of %d shards", len(used), playerShards)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentSecurityPolicy lets the dashboard load its own pages, styles and
+// forms, plus avatars from any HTTPS host, and nothing else.
+const contentSecurityPolicy = "default-src 'self'; img-src 'self' https: data:; " +
+	"frame-ancestors 'none'; form-action 'self'; base-uri 'none'"
+
+// CORSOptions lets pages on other origins call the API from a browser.
+// An AllowedOrigins entry of "*" allows any origin, but AllowCredentials
+// only ever applies to the origins listed by name. MaxAge is how long
+// browsers may cache a preflight answer.
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// WithCORS answers cross-origin requests and preflights from the
+// allowed origins.
+func WithCORS(opts CORSOptions) ServerOption {
+	return func(p *PlayerServer) {
+		if len(opts.AllowedMethods) == 0 {
+			opts.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+		}
+		if len(opts.AllowedHeaders) == 0 {
+			opts.AllowedHeaders = []string{"Content-Type"}
+		}
+		if opts.MaxAge == 0 {
+			opts.MaxAge = 10 * time.Minute
+		}
+		p.cors = &opts
+	}
+}
+
+// allowsOrigin reports whether origin may call the API, and whether it
+// is listed by name rather than only matched by a wildcard.
+func (o *CORSOptions) allowsOrigin(origin string) (allowed, listed bool) {
+	for _, a := range o.AllowedOrigins {
+		if a == origin {
+			return true, true
+		}
+		if a == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+func (o *CORSOptions) allowsMethod(method string) bool {
+	for _, allowed := range o.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *CORSOptions) allowsHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		allowed := false
+		for _, a := range o.AllowedHeaders {
+			if strings.EqualFold(a, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *CORSOptions) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		allowed, listed := o.allowsOrigin(origin)
+		if !allowed {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Echoing any origin with credentials would let every site act
+		// as the signed-in user, so a wildcard match gets "*", which
+		// browsers never send credentials to.
+		if listed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if o.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if !preflight {
+			if len(o.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(o.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !o.allowsMethod(r.Header.Get("Access-Control-Request-Method")) ||
+			!o.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(o.AllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(o.AllowedHeaders, ", "))
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(o.MaxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// securityHeaders sets headers that keep browsers from sniffing types,
+// framing the dashboard or leaking URLs to other sites.
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Cross-Origin-Opener-Policy", "same-origin")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
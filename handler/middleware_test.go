@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	server := NewPlayerServer(NewInMemoryPlayerStore(), WithCORS(CORSOptions{
+		AllowedOrigins: []string{"https://dashboard.example"},
+		ExposedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Hour,
+	}))
+
+	t.Run("allows requests from an allowed origin", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://dashboard.example"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertHeader(t, response, "Access-Control-Allow-Origin", "https://dashboard.example")
+		assertHeader(t, response, "Access-Control-Expose-Headers", "Content-Type")
+		assertHeader(t, response, "Vary", "Origin")
+	})
+
+	t.Run("adds nothing for other origins", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://evil.example"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertHeader(t, response, "Access-Control-Allow-Origin", "")
+	})
+
+	t.Run("answers preflights", func(t *testing.T) {
+		request := newCORSRequest(http.MethodOptions, "/players/Pepper", "https://dashboard.example")
+		request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		request.Header.Set("Access-Control-Request-Headers", "content-type")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertResponseCode(t, response.Code, http.StatusNoContent)
+		assertHeader(t, response, "Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+		assertHeader(t, response, "Access-Control-Allow-Headers", "Content-Type")
+		assertHeader(t, response, "Access-Control-Max-Age", "3600")
+	})
+
+	t.Run("refuses preflights it can't allow", func(t *testing.T) {
+		cases := map[string][3]string{
+			"origin":  {"https://evil.example", http.MethodPost, ""},
+			"method":  {"https://dashboard.example", http.MethodPatch, ""},
+			"headers": {"https://dashboard.example", http.MethodPost, "X-Secret"},
+		}
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				request := newCORSRequest(http.MethodOptions, "/league", c[0])
+				request.Header.Set("Access-Control-Request-Method", c[1])
+				request.Header.Set("Access-Control-Request-Headers", c[2])
+				response := httptest.NewRecorder()
+
+				server.ServeHTTP(response, request)
+
+				assertResponseCode(t, response.Code, http.StatusForbidden)
+			})
+		}
+	})
+
+	t.Run("uses a wildcard for any origin without credentials", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithCORS(CORSOptions{AllowedOrigins: []string{"*"}}))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://anywhere.example"))
+
+		assertHeader(t, response, "Access-Control-Allow-Origin", "*")
+	})
+
+	t.Run("allows credentials only from listed origins", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithCORS(CORSOptions{
+			AllowedOrigins:   []string{"https://dashboard.example", "*"},
+			AllowCredentials: true,
+		}))
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://dashboard.example"))
+		assertHeader(t, response, "Access-Control-Allow-Origin", "https://dashboard.example")
+		assertHeader(t, response, "Access-Control-Allow-Credentials", "true")
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://anywhere.example"))
+		assertHeader(t, response, "Access-Control-Allow-Origin", "*")
+		assertHeader(t, response, "Access-Control-Allow-Credentials", "")
+	})
+
+	t.Run("is off unless configured", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newCORSRequest(http.MethodGet, "/league", "https://dashboard.example"))
+
+		assertHeader(t, response, "Access-Control-Allow-Origin", "")
+	})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	server := NewPlayerServer(NewInMemoryPlayerStore())
+
+	for _, path := range []string{"/league", "/ui/", "/players/Nobody"} {
+		t.Run(path, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, newUIRequest(path))
+
+			assertHeader(t, response, "Content-Security-Policy", contentSecurityPolicy)
+			assertHeader(t, response, "X-Content-Type-Options", "nosniff")
+			assertHeader(t, response, "X-Frame-Options", "DENY")
+			assertHeader(t, response, "Referrer-Policy", "no-referrer")
+			assertHeader(t, response, "Strict-Transport-Security", "")
+		})
+	}
+
+	t.Run("sets HSTS over TLS", func(t *testing.T) {
+		ts := httptest.NewTLSServer(server)
+		defer ts.Close()
+
+		res, err := ts.Client().Get(ts.URL + "/league")
+
+		assertNoStoreError(t, err)
+		res.Body.Close()
+		if res.Header.Get("Strict-Transport-Security") == "" {
+			t.Error("expected Strict-Transport-Security over TLS")
+		}
+	})
+}
+
+func newCORSRequest(method, path, origin string) *http.Request {
+	request, _ := http.NewRequest(method, path, nil)
+	request.Header.Set("Origin", origin)
+	return request
+}
+
+func assertHeader(t *testing.T, response *httptest.ResponseRecorder, name, want string) {
+	t.Helper()
+	if got := response.Header().Get(name); got != want {
+		t.Errorf("got %s %q want %q", name, got, want)
+	}
+}
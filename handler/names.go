@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidName      = errors.New("invalid player name")
+	ErrMergeUnsupported = errors.New("player store cannot merge players")
+)
+
+// NamePolicy decides when two spellings are the same player.
+type NamePolicy struct {
+	FoldCase     bool
+	Trim         bool
+	NormalizeNFC bool
+	MaxLength    int
+	Allowed      func(r rune) bool
+}
+
+var DefaultNamePolicy = NamePolicy{
+	FoldCase:     true,
+	Trim:         true,
+	NormalizeNFC: true,
+	MaxLength:    64,
+	Allowed:      defaultNameRune,
+}
+
+// PlayerMerger is implemented by stores that can fold one player's
+// record into another's.
+type PlayerMerger interface {
+	MergePlayer(from, into string) error
+}
+
+// NameEnforcer is implemented by stores that normalize every name they
+// write and read, so callers that bypass the server can't bring back a
+// spelling the policy merged. Merges still accept any name to merge from.
+type NameEnforcer interface {
+	EnforceNames(policy NamePolicy)
+}
+
+func defaultNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || strings.ContainsRune(" -_.'", r)
+}
+
+// Normalize returns the canonical form of name, or ErrInvalidName when
+// the policy doesn't allow it.
+func (p NamePolicy) Normalize(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidName)
+	}
+	if p.Trim {
+		name = strings.TrimSpace(name)
+	}
+	if p.NormalizeNFC {
+		name = norm.NFC.String(name)
+	}
+	if p.FoldCase {
+		name = strings.ToLower(name)
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%w: name is empty", ErrInvalidName)
+	}
+	if p.MaxLength > 0 && utf8.RuneCountInString(name) > p.MaxLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidName, p.MaxLength)
+	}
+	for _, r := range name {
+		if r == '/' || (p.Allowed != nil && !p.Allowed(r)) {
+			return "", fmt.Errorf("%w: %q is not allowed", ErrInvalidName, r)
+		}
+	}
+	return name, nil
+}
+
+// MigrateNames merges every player whose name normalizes to another
+// player's and returns how many were merged away. Names the policy
+// rejects are left alone and reported in the error.
+func MigrateNames(store PlayerStore, policy NamePolicy) (int, error) {
+	merger, ok := store.(PlayerMerger)
+	if !ok {
+		return 0, ErrMergeUnsupported
+	}
+
+	league := store.GetLeague()
+	sort.Slice(league, func(i, j int) bool { return league[i].Name < league[j].Name })
+
+	merged := 0
+	var invalid []string
+	for _, player := range league {
+		name, err := policy.Normalize(player.Name)
+		if err != nil {
+			invalid = append(invalid, player.Name)
+			continue
+		}
+		if name == player.Name {
+			continue
+		}
+		if err := merger.MergePlayer(player.Name, name); err != nil {
+			return merged, err
+		}
+		merged++
+	}
+	if len(invalid) > 0 {
+		return merged, fmt.Errorf("%w: could not migrate %q", ErrInvalidName, invalid)
+	}
+	return merged, nil
+}
+
+// WithNamePolicy normalizes every player name the server receives, so
+// "Pepper", "pepper" and " Pepper" are one player. Stores that are
+// NameEnforcers apply the policy to their writes too.
+func WithNamePolicy(policy NamePolicy) ServerOption {
+	return func(p *PlayerServer) {
+		p.names = &policy
+		if enforcer, ok := p.store.(NameEnforcer); ok {
+			enforcer.EnforceNames(policy)
+		}
+	}
+}
+
+// enforcedNames is embedded by stores to hold the policy they apply to
+// the names they write.
+type enforcedNames struct {
+	policy atomic.Pointer[NamePolicy]
+}
+
+func (n *enforcedNames) EnforceNames(policy NamePolicy) {
+	n.policy.Store(&policy)
+}
+
+func (n *enforcedNames) normalize(name string) (string, error) {
+	policy := n.policy.Load()
+	if policy == nil {
+		return name, nil
+	}
+	return policy.Normalize(name)
+}
+
+// lookup returns the name to read a player under: the normalized form
+// when the policy accepts name, and name as given otherwise.
+func (n *enforcedNames) lookup(name string) string {
+	if normalized, err := n.normalize(name); err == nil {
+		return normalized
+	}
+	return name
+}
+
+func (n *enforcedNames) normalizeAll(names []string) ([]string, error) {
+	out := make([]string, len(names))
+	for i, name := range names {
+		var err error
+		if out[i], err = n.normalize(name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (n *enforcedNames) normalizeBatch(batch []WinIncrement) ([]WinIncrement, error) {
+	out := make([]WinIncrement, len(batch))
+	for i, inc := range batch {
+		name, err := n.normalize(inc.Name)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = WinIncrement{Name: name, Wins: inc.Wins}
+	}
+	return out, nil
+}
+
+func (p *PlayerServer) playerName(name string) (string, error) {
+	if p.names == nil {
+		return name, nil
+	}
+	return p.names.Normalize(name)
+}
+
+func (p *PlayerServer) playerNames(names []string) ([]string, error) {
+	out := make([]string, len(names))
+	for i, name := range names {
+		n, err := p.playerName(name)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// mergePlayerState moves from's wins, history and badges onto into,
+// keeps into's profile and streak, and renames from in every team.
+func mergePlayerState(from, into string, scores map[string]int, history map[string][]time.Time, profiles map[string]Profile, teams map[string]Team, achievements map[string][]Achievement, streaks map[string]int) {
+	if from == into {
+		return
+	}
+	if wins, ok := scores[from]; ok {
+		scores[into] += wins
+		delete(scores, from)
+	}
+	if wins := history[from]; len(wins) > 0 {
+		merged := append(history[into], wins...)
+		sort.Slice(merged, func(i, j int) bool { return merged[i].Before(merged[j]) })
+		history[into] = trimHistory(merged)
+	}
+	delete(history, from)
+
+	if profile, ok := profiles[from]; ok {
+		if _, exists := profiles[into]; !exists {
+			profiles[into] = profile
+		}
+		delete(profiles, from)
+	}
+
+	if badges, ok := achievements[from]; ok {
+		merged := append([]Achievement(nil), achievements[into]...)
+		for _, a := range badges {
+			merged = mergeAchievement(merged, a)
+		}
+		achievements[into] = merged
+		delete(achievements, from)
+	}
+	delete(streaks, from)
+
+	for name, team := range teams {
+		members := make([]string, 0, len(team.Members))
+		seen := make(map[string]bool)
+		for _, m := range team.Members {
+			if m == from {
+				m = into
+			}
+			if !seen[m] {
+				seen[m] = true
+				members = append(members, m)
+			}
+		}
+		team.Members = members
+		teams[name] = team
+	}
+}
+
+// mergeAchievement adds a to held, keeping the earlier award when the
+// badge is already there.
+func mergeAchievement(held []Achievement, a Achievement) []Achievement {
+	for i := range held {
+		if held[i].Badge == a.Badge {
+			if a.AwardedAt.Before(held[i].AwardedAt) {
+				held[i] = a
+			}
+			return held
+		}
+	}
+	return append(held, a)
+}
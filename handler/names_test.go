@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamePolicy(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"folds case", "Pepper", "pepper"},
+		{"trims spaces", " Pepper ", "pepper"},
+		{"composes accents", "Ame\u0301lie", "am\u00e9lie"},
+		{"keeps precomposed accents", "Am\u00e9lie", "am\u00e9lie"},
+		{"orders stacked accents", "a\u0307\u0323", "\u1ea1\u0307"},
+		{"composes other scripts", "\u1100\u1161", "\uac00"},
+		{"allows punctuation", "O'Brien-Smith Jr.", "o'brien-smith jr."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := DefaultNamePolicy.Normalize(c.in)
+			assertNoStoreError(t, err)
+			if got != c.want {
+				t.Errorf("got %q want %q", got, c.want)
+			}
+		})
+	}
+
+	rejected := map[string]string{
+		"empty":        "  ",
+		"too long":     strings.Repeat("a", 65),
+		"slash":        "a/b",
+		"symbol":       "pepper!",
+		"control":      "pep\x00per",
+		"invalid utf8": "pep\xffper",
+	}
+	for name, in := range rejected {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := DefaultNamePolicy.Normalize(in); !errors.Is(err, ErrInvalidName) {
+				t.Errorf("got %v want ErrInvalidName", err)
+			}
+		})
+	}
+
+	t.Run("only applies the rules it enables", func(t *testing.T) {
+		got, err := NamePolicy{Trim: true}.Normalize(" Pepper!")
+		assertNoStoreError(t, err)
+		if got != "Pepper!" {
+			t.Errorf("got %q want %q", got, "Pepper!")
+		}
+	})
+}
+
+func TestServerNamePolicy(t *testing.T) {
+	t.Run("treats spellings of a name as one player", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store, WithNamePolicy(DefaultNamePolicy))
+
+		recordWins(server, "Pepper", "pepper", "Pepper%20")
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetScoreRequest("PEPPER"))
+		assertResponseBody(t, response.Body.String(), "3")
+		assertLeague(t, store.GetLeague(), []Player{{Name: "pepper", Wins: 3}})
+	})
+
+	t.Run("rejects names the policy does not allow", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithNamePolicy(DefaultNamePolicy))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newPostWinRequest("pepper!"))
+
+		assertResponseCode(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("normalizes team members", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store, WithNamePolicy(DefaultNamePolicy))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newPutTeamRequest("Spicy", `{"Members": ["Pepper", " CHILLI"]}`))
+
+		assertResponseCode(t, response.Code, http.StatusCreated)
+		team, _ := store.GetTeam("Spicy")
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"pepper", "chilli"}})
+	})
+
+	t.Run("has the store enforce the policy on its writes", func(t *testing.T) {
+		wal := newTestWALStore(t, t.TempDir(), WALOptions{})
+		defer wal.Close()
+		stores := map[string]PlayerStore{
+			"memory": NewInMemoryPlayerStore(),
+			"wal":    wal,
+			"events": newTestEventStore(t, NewInMemoryEventLog()),
+			"leader": NewReplicationLeader(NewInMemoryPlayerStore()),
+		}
+		for name, store := range stores {
+			t.Run(name, func(t *testing.T) {
+				NewPlayerServer(store, WithNamePolicy(DefaultNamePolicy))
+
+				store.RecordWin(" Pepper")
+				store.RecordWin("pepper!")
+
+				assertLeague(t, store.GetLeague(), []Player{{Name: "pepper", Wins: 1}})
+				assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+				if history, ok := store.(HistoryStore); ok && len(history.WinHistory(" PEPPER")) != 1 {
+					t.Errorf("got history %v want one win", history.WinHistory(" PEPPER"))
+				}
+				err := RecordWins(store, []WinIncrement{{Name: "Floyd", Wins: 1}, {Name: "", Wins: 1}})
+				if _, ok := store.(BatchRecorder); ok && !errors.Is(err, ErrInvalidName) {
+					t.Errorf("got %v want ErrInvalidName", err)
+				}
+				if teams, ok := store.(TeamStore); ok {
+					assertNoStoreError(t, teams.SaveTeam(Team{Name: "Spicy", Members: []string{"PEPPER"}}))
+					team, _ := teams.GetTeam("Spicy")
+					assertTeam(t, team, Team{Name: "Spicy", Members: []string{"pepper"}})
+				}
+				if profiles, ok := store.(ProfileStore); ok {
+					assertNoStoreError(t, profiles.SaveProfile("Pepper", Profile{DisplayName: "Pep"}))
+					_, err := profiles.GetProfile("PEPPER")
+					assertNoStoreError(t, err)
+					assertNoStoreError(t, profiles.DeleteProfile(" Pepper"))
+				}
+			})
+		}
+	})
+}
+
+func TestMigrateNames(t *testing.T) {
+	seed := func(store PlayerStore) {
+		for _, name := range []string{"Pepper", "pepper", "Pepper ", "Floyd"} {
+			store.RecordWin(name)
+		}
+	}
+
+	t.Run("merges duplicates in memory", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		seed(store)
+		store.SaveProfile("Pepper", Profile{DisplayName: "Pep"})
+		store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "pepper"}})
+
+		merged, err := MigrateNames(store, DefaultNamePolicy)
+
+		assertNoStoreError(t, err)
+		assertScoreEquals(t, merged, 3)
+		assertLeague(t, sortedLeague(store.GetLeague()), []Player{{Name: "floyd", Wins: 1}, {Name: "pepper", Wins: 3}})
+		if got := len(store.WinHistory("pepper")); got != 3 {
+			t.Errorf("got %d wins in history want 3", got)
+		}
+		profile, err := store.GetProfile("pepper")
+		assertNoStoreError(t, err)
+		if profile.DisplayName != "Pep" {
+			t.Errorf("got profile %+v", profile)
+		}
+		team, _ := store.GetTeam("Spicy")
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"pepper"}})
+	})
+
+	t.Run("merges badges keeping the earliest award", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		seed(store)
+		early, late := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		store.Award("Pepper", Achievement{Badge: BadgeFirstWin, AwardedAt: late})
+		store.Award("pepper", Achievement{Badge: BadgeFirstWin, AwardedAt: early})
+		store.Award("Pepper ", Achievement{Badge: BadgeCentury, AwardedAt: late})
+
+		_, err := MigrateNames(store, DefaultNamePolicy)
+
+		assertNoStoreError(t, err)
+		got := store.Achievements("pepper")
+		if len(got) != 2 || !got[0].AwardedAt.Equal(early) || got[1].Badge != BadgeCentury {
+			t.Errorf("got achievements %+v", got)
+		}
+	})
+
+	t.Run("keeps the streak of the player merged into", func(t *testing.T) {
+		store := newTestEventStore(t, NewInMemoryEventLog())
+		store.RecordWin("pepper")
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+
+		_, err := MigrateNames(store, DefaultNamePolicy)
+
+		assertNoStoreError(t, err)
+		if got := store.Streak("pepper"); got != 0 {
+			t.Errorf("got streak %d want 0", got)
+		}
+	})
+
+	t.Run("merges survive a WAL restart", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		seed(store)
+
+		_, err := MigrateNames(store, DefaultNamePolicy)
+		assertNoStoreError(t, err)
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertLeague(t, sortedLeague(store.GetLeague()), []Player{{Name: "floyd", Wins: 1}, {Name: "pepper", Wins: 3}})
+	})
+
+	t.Run("merges are replayed from events", func(t *testing.T) {
+		events := NewInMemoryEventLog()
+		store := newTestEventStore(t, events)
+		seed(store)
+
+		_, err := MigrateNames(store, DefaultNamePolicy)
+		assertNoStoreError(t, err)
+
+		rebuilt := newTestEventStore(t, events)
+		assertLeague(t, sortedLeague(rebuilt.GetLeague()), []Player{{Name: "floyd", Wins: 1}, {Name: "pepper", Wins: 3}})
+	})
+
+	t.Run("merges reach the leader's followers", func(t *testing.T) {
+		leader := NewReplicationLeader(NewInMemoryPlayerStore())
+		seed(leader)
+		server := httptest.NewServer(NewPlayerServer(leader))
+		t.Cleanup(server.Close)
+		follower := startFollower(t, server.URL)
+		waitForScore(t, follower, "Floyd", 1)
+
+		_, err := MigrateNames(leader, DefaultNamePolicy)
+		assertNoStoreError(t, err)
+
+		waitForScore(t, follower, "pepper", 3)
+		assertLeague(t, sortedLeague(follower.GetLeague()), []Player{{Name: "floyd", Wins: 1}, {Name: "pepper", Wins: 3}})
+		follower.EnforceNames(DefaultNamePolicy)
+		assertScoreEquals(t, follower.GetPlayerScore("Pepper"), 3)
+	})
+
+	t.Run("reports names it cannot migrate", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Pepper!")
+
+		_, err := MigrateNames(store, DefaultNamePolicy)
+
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("got %v want ErrInvalidName", err)
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Pepper!"), 1)
+	})
+
+	t.Run("needs a store that can merge", func(t *testing.T) {
+		_, err := MigrateNames(&StubPlayerStore{}, DefaultNamePolicy)
+		if !errors.Is(err, ErrMergeUnsupported) {
+			t.Errorf("got %v want ErrMergeUnsupported", err)
+		}
+	})
+}
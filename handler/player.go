@@ -1,6 +1,24 @@
 package handler
 
+import "time"
+
 type Player struct {
-	Name string
-	Wins int
+	Name         string
+	Wins         int
+	Profile      *Profile `json:",omitempty"`
+	Achievements []string `json:",omitempty"`
+}
+
+type Profile struct {
+	DisplayName string    `json:"displayName"`
+	AvatarURL   string    `json:"avatarUrl,omitempty"`
+	Team        string    `json:"team,omitempty"`
+	JoinedAt    time.Time `json:"joinedAt"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+type Team struct {
+	Name    string
+	Members []string
+	Wins    int
 }
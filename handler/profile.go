@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	maxProfileFieldLength = 64
+	maxProfileTags        = 20
+	maxProfileTagLength   = 32
+)
+
+var (
+	ErrProfileNotFound     = errors.New("player has no profile")
+	ErrProfilesUnsupported = errors.New("player store does not support profiles")
+)
+
+type ProfileStore interface {
+	GetProfile(name string) (Profile, error)
+	SaveProfile(name string, profile Profile) error
+	DeleteProfile(name string) error
+}
+
+func (p Profile) Validate() error {
+	if p.DisplayName == "" {
+		return errors.New("displayName is required")
+	}
+	if len(p.DisplayName) > maxProfileFieldLength {
+		return fmt.Errorf("displayName must be at most %d characters", maxProfileFieldLength)
+	}
+	if len(p.Team) > maxProfileFieldLength {
+		return fmt.Errorf("team must be at most %d characters", maxProfileFieldLength)
+	}
+	if p.AvatarURL != "" {
+		u, err := url.Parse(p.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("avatarUrl must be an absolute http or https URL")
+		}
+	}
+	if len(p.Tags) > maxProfileTags {
+		return fmt.Errorf("at most %d tags are allowed", maxProfileTags)
+	}
+	for _, tag := range p.Tags {
+		if tag == "" || len(tag) > maxProfileTagLength {
+			return fmt.Errorf("tags must be between 1 and %d characters", maxProfileTagLength)
+		}
+	}
+	return nil
+}
+
+func (p Profile) clone() Profile {
+	if p.Tags != nil {
+		p.Tags = append([]string(nil), p.Tags...)
+	}
+	return p
+}
+
+func (p *PlayerServer) profileHandler(w http.ResponseWriter, r *http.Request, player string) {
+	profiles, ok := p.store.(ProfileStore)
+	if !ok {
+		http.Error(w, ErrProfilesUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := profiles.GetProfile(player)
+		if err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, profile)
+	case http.MethodPut:
+		p.saveProfile(w, r, profiles, player)
+	case http.MethodDelete:
+		if err := profiles.DeleteProfile(player); err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *PlayerServer) saveProfile(w http.ResponseWriter, r *http.Request, profiles ProfileStore, player string) {
+	var profile Profile
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&profile); err != nil {
+		http.Error(w, "invalid profile: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := profile.Validate(); err != nil {
+		http.Error(w, "invalid profile: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	existing, err := profiles.GetProfile(player)
+	switch {
+	case errors.Is(err, ErrProfileNotFound):
+		status = http.StatusCreated
+	case err != nil:
+		writeProfileError(w, err)
+		return
+	}
+	if profile.JoinedAt.IsZero() {
+		profile.JoinedAt = existing.JoinedAt
+	}
+	if profile.JoinedAt.IsZero() {
+		profile.JoinedAt = time.Now().UTC()
+	}
+
+	if err := profiles.SaveProfile(player, profile); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, status, profile)
+}
+
+func (p *PlayerServer) withProfiles(league []Player) []Player {
+	profiles, ok := p.store.(ProfileStore)
+	if !ok {
+		return league
+	}
+	for i := range league {
+		if profile, err := profiles.GetProfile(league[i].Name); err == nil {
+			league[i].Profile = &profile
+		}
+	}
+	return league
+}
+
+func writeProfileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrProfileNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrProfilesUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
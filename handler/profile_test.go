@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Run("returns 404 for a player without a profile", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newGetProfileRequest("Pepper"))
+
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("creates, replaces and deletes a profile", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPutProfileRequest("Pepper", `{"displayName":"Pep","team":"Red","tags":["lefty"]}`))
+		assertResponseCode(t, response.Code, http.StatusCreated)
+		created := getProfileFromResponse(t, response)
+		if created.JoinedAt.IsZero() {
+			t.Error("expected joinedAt to default to now")
+		}
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newPutProfileRequest("Pepper", `{"displayName":"Pepper P","avatarUrl":"https://example.com/p.png"}`))
+		assertResponseCode(t, response.Code, http.StatusOK)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newGetProfileRequest("Pepper"))
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		want := Profile{
+			DisplayName: "Pepper P",
+			AvatarURL:   "https://example.com/p.png",
+			JoinedAt:    created.JoinedAt,
+		}
+		assertProfile(t, getProfileFromResponse(t, response), want)
+
+		response = httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/players/Pepper/profile", nil)
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusNoContent)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newGetProfileRequest("Pepper"))
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("rejects invalid profiles", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		bodies := map[string]string{
+			"malformed json":      `{"displayName":`,
+			"unknown field":       `{"displayName":"Pep","nickname":"P"}`,
+			"missing name":        `{"team":"Red"}`,
+			"relative avatar url": `{"displayName":"Pep","avatarUrl":"/p.png"}`,
+			"empty tag":           `{"displayName":"Pep","tags":[""]}`,
+			"long display name":   `{"displayName":"` + strings.Repeat("p", maxProfileFieldLength+1) + `"}`,
+		}
+
+		for title, body := range bodies {
+			t.Run(title, func(t *testing.T) {
+				response := httptest.NewRecorder()
+				server.ServeHTTP(response, newPutProfileRequest("Pepper", body))
+				assertResponseCode(t, response.Code, http.StatusBadRequest)
+			})
+		}
+	})
+
+	t.Run("returns 501 when the store has no profiles", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newGetProfileRequest("Pepper"))
+
+		assertResponseCode(t, response.Code, http.StatusNotImplemented)
+	})
+
+	t.Run("embeds profiles in the league on request", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Pepper")
+		joined := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+		store.SaveProfile("Pepper", Profile{DisplayName: "Pep", JoinedAt: joined})
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newLeagueRequest())
+		assertLeague(t, getLeagueFromResponse(t, response.Body), []Player{{Name: "Pepper", Wins: 1}})
+
+		response = httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/league?profiles=true", nil)
+		server.ServeHTTP(response, request)
+		want := []Player{{Name: "Pepper", Wins: 1, Profile: &Profile{DisplayName: "Pep", JoinedAt: joined}}}
+		assertLeague(t, getLeagueFromResponse(t, response.Body), want)
+	})
+}
+
+func newGetProfileRequest(name string) *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, "/players/"+name+"/profile", nil)
+	return request
+}
+
+func newPutProfileRequest(name, body string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPut, "/players/"+name+"/profile", strings.NewReader(body))
+	return request
+}
+
+func getProfileFromResponse(t *testing.T, response *httptest.ResponseRecorder) Profile {
+	t.Helper()
+	var got Profile
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+		t.Fatalf("Unable to parse response %q into a Profile, %v", response.Body, err)
+	}
+	return got
+}
+
+func assertProfile(t *testing.T, got, want Profile) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got profile %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,559 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	replicationPath = "/replication/events"
+
+	// maxReplicationLog is how many events the leader keeps for followers
+	// to catch up from. Older ones are dropped, and a follower that falls
+	// further behind starts again from a snapshot.
+	maxReplicationLog = 10000
+)
+
+// ReplicationEvent is one line of the replication stream. A line with an
+// Epoch is a snapshot: it replaces the follower's whole league with
+// League as of Seq. A line with Into merges Name into that player.
+type ReplicationEvent struct {
+	Seq    uint64   `json:"seq"`
+	Name   string   `json:"name,omitempty"`
+	Wins   int      `json:"wins,omitempty"`
+	Into   string   `json:"into,omitempty"`
+	Epoch  string   `json:"epoch,omitempty"`
+	League []Player `json:"league,omitempty"`
+}
+
+type replicationSource interface {
+	EventsSince(epoch string, seq uint64) (snapshot *ReplicationEvent, events []ReplicationEvent, changed <-chan struct{})
+}
+
+// ReplicationLeader wraps the store that owns the league and keeps an
+// ordered log of recent wins so followers can stream and replay it.
+//
+// Sequence numbers only mean something within one epoch, which is new
+// every time a leader starts. A follower from another epoch, or one that
+// has fallen behind the kept log, is sent a snapshot first.
+type ReplicationLeader struct {
+	store PlayerStore
+	epoch string
+	enforcedNames
+
+	mu        sync.Mutex
+	events    []ReplicationEvent
+	compacted uint64
+	maxEvents int
+	changed   chan struct{}
+}
+
+func NewReplicationLeader(store PlayerStore) *ReplicationLeader {
+	epoch, err := randomHex(8)
+	if err != nil {
+		epoch = strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return &ReplicationLeader{
+		store:     store,
+		epoch:     epoch,
+		maxEvents: maxReplicationLog,
+		changed:   make(chan struct{}),
+	}
+}
+
+func (l *ReplicationLeader) GetPlayerScore(name string) int {
+	return l.store.GetPlayerScore(name)
+}
+
+func (l *ReplicationLeader) GetLeague() []Player {
+	return l.store.GetLeague()
+}
+
+// RecordWin records the win as a batch of one where the store supports
+// batches, so a win the store failed to keep is never replicated.
+func (l *ReplicationLeader) RecordWin(name string) {
+	normalized, err := l.normalize(name)
+	if err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	name = normalized
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := RecordWins(l.store, []WinIncrement{{Name: name, Wins: 1}}); err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	l.appendEvent(ReplicationEvent{Name: name, Wins: 1})
+}
+
+// MergePlayer emits the merge so followers fold the same players
+// together.
+func (l *ReplicationLeader) MergePlayer(from, into string) error {
+	merger, ok := l.store.(PlayerMerger)
+	if !ok {
+		return ErrMergeUnsupported
+	}
+	into, err := l.normalize(into)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := merger.MergePlayer(from, into); err != nil {
+		return err
+	}
+	l.appendEvent(ReplicationEvent{Name: from, Into: into})
+	return nil
+}
+
+// EnforceNames applies the policy to the names the leader replicates and
+// to its store.
+func (l *ReplicationLeader) EnforceNames(policy NamePolicy) {
+	l.enforcedNames.EnforceNames(policy)
+	if enforcer, ok := l.store.(NameEnforcer); ok {
+		enforcer.EnforceNames(policy)
+	}
+}
+
+func (l *ReplicationLeader) GetProfile(name string) (Profile, error) {
+	profiles, ok := l.store.(ProfileStore)
+	if !ok {
+		return Profile{}, ErrProfilesUnsupported
+	}
+	return profiles.GetProfile(name)
+}
+
+func (l *ReplicationLeader) SaveProfile(name string, profile Profile) error {
+	profiles, ok := l.store.(ProfileStore)
+	if !ok {
+		return ErrProfilesUnsupported
+	}
+	return profiles.SaveProfile(name, profile)
+}
+
+func (l *ReplicationLeader) DeleteProfile(name string) error {
+	profiles, ok := l.store.(ProfileStore)
+	if !ok {
+		return ErrProfilesUnsupported
+	}
+	return profiles.DeleteProfile(name)
+}
+
+func (l *ReplicationLeader) GetTeam(name string) (Team, error) {
+	teams, ok := l.store.(TeamStore)
+	if !ok {
+		return Team{}, ErrTeamsUnsupported
+	}
+	return teams.GetTeam(name)
+}
+
+func (l *ReplicationLeader) GetTeams() ([]Team, error) {
+	teams, ok := l.store.(TeamStore)
+	if !ok {
+		return nil, ErrTeamsUnsupported
+	}
+	return teams.GetTeams()
+}
+
+func (l *ReplicationLeader) SaveTeam(team Team) error {
+	teams, ok := l.store.(TeamStore)
+	if !ok {
+		return ErrTeamsUnsupported
+	}
+	return teams.SaveTeam(team)
+}
+
+// RecordTeamWin emits one event per member so followers credit them too.
+func (l *ReplicationLeader) RecordTeamWin(name string) error {
+	teams, ok := l.store.(TeamStore)
+	if !ok {
+		return ErrTeamsUnsupported
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	team, err := teams.GetTeam(name)
+	if err != nil {
+		return err
+	}
+	if err := teams.RecordTeamWin(name); err != nil {
+		return err
+	}
+	for _, m := range team.Members {
+		l.appendEvent(ReplicationEvent{Name: m, Wins: 1})
+	}
+	return nil
+}
+
+func (l *ReplicationLeader) SearchPlayers(query string) []NameMatch {
+	return searchPlayers(l.store, query)
+}
+
+func (l *ReplicationLeader) WinHistory(name string) []time.Time {
+	history, ok := l.store.(HistoryStore)
+	if !ok {
+		return nil
+	}
+	return history.WinHistory(name)
+}
+
+func (l *ReplicationLeader) Ping(ctx context.Context) error {
+	if pinger, ok := l.store.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (l *ReplicationLeader) Close() error {
+	if closer, ok := l.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// EventsSince returns the events after seq in epoch and a channel that
+// is closed when more arrive. When seq can't be continued from, because
+// it belongs to another epoch or is no longer in the log, it also returns
+// a snapshot to start again from.
+func (l *ReplicationLeader) EventsSince(epoch string, seq uint64) (*ReplicationEvent, []ReplicationEvent, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last := l.compacted + uint64(len(l.events))
+
+	// Wins only reach the store through the leader, under l.mu, so the
+	// league read here is exactly the state as of last.
+	var snapshot *ReplicationEvent
+	if epoch != l.epoch || seq < l.compacted || seq > last {
+		snapshot = &ReplicationEvent{Seq: last, Epoch: l.epoch, League: l.store.GetLeague()}
+		seq = last
+	}
+	events := make([]ReplicationEvent, last-seq)
+	copy(events, l.events[seq-l.compacted:])
+	return snapshot, events, l.changed
+}
+
+// appendEvent numbers and logs a change, dropping the oldest half of the
+// log once it is full. The caller holds l.mu.
+func (l *ReplicationLeader) appendEvent(e ReplicationEvent) {
+	e.Seq = l.compacted + uint64(len(l.events)) + 1
+	l.events = append(l.events, e)
+	if len(l.events) > l.maxEvents {
+		drop := len(l.events) - l.maxEvents/2
+		l.events = append([]ReplicationEvent(nil), l.events[drop:]...)
+		l.compacted += uint64(drop)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (p *PlayerServer) replicationHandler(w http.ResponseWriter, r *http.Request) {
+	source, ok := p.store.(replicationSource)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	from, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+	if err != nil && r.URL.Query().Get("from") != "" {
+		http.Error(w, "from must be a sequence number", http.StatusBadRequest)
+		return
+	}
+	epoch := r.URL.Query().Get("epoch")
+
+	w.Header().Set("content-type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	for {
+		snapshot, events, changed := source.EventsSince(epoch, from)
+		if snapshot != nil {
+			if err := enc.Encode(snapshot); err != nil {
+				return
+			}
+			epoch, from = snapshot.Epoch, snapshot.Seq
+		}
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			from = e.Seq
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-p.stopping:
+			return
+		}
+	}
+}
+
+// ReplicationFollower serves reads from a local copy of the leader's
+// league and forwards writes to the leader.
+type ReplicationFollower struct {
+	LeaderURL  string
+	Client     *http.Client
+	RetryDelay time.Duration
+
+	// Writes are normalized by the leader; the policy is only needed to
+	// read the local copy under the right names.
+	enforcedNames
+
+	mu      sync.Mutex
+	store   *InMemoryPlayerStore
+	epoch   string
+	applied uint64
+}
+
+func NewReplicationFollower(leaderURL string) *ReplicationFollower {
+	return &ReplicationFollower{
+		LeaderURL:  leaderURL,
+		Client:     http.DefaultClient,
+		RetryDelay: time.Second,
+		store:      NewInMemoryPlayerStore(),
+	}
+}
+
+func (f *ReplicationFollower) GetPlayerScore(name string) int {
+	return f.local().GetPlayerScore(f.lookup(name))
+}
+
+func (f *ReplicationFollower) GetLeague() []Player {
+	return f.local().GetLeague()
+}
+
+// local returns the follower's copy of the league, which a snapshot
+// replaces wholesale.
+func (f *ReplicationFollower) local() *InMemoryPlayerStore {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.store
+}
+
+func (f *ReplicationFollower) RecordWin(name string) {
+	res, err := f.Client.Post(f.playerURL(name), "", nil)
+	if err != nil {
+		log.Printf("could not forward win for %q to leader: %v", name, err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		log.Printf("leader rejected win for %q with status %d", name, res.StatusCode)
+	}
+}
+
+// RecordWins forwards the whole batch to the leader, which applies it as
+// one change.
+func (f *ReplicationFollower) RecordWins(batch []WinIncrement) error {
+	return f.callLeader(http.MethodPost, f.playerURL(batchPath), batch, nil, ErrBatchUnsupported, ErrBatchUnsupported)
+}
+
+// Ping checks the follower can reach the leader, which it needs for
+// writes.
+func (f *ReplicationFollower) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.LeaderURL+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("leader is unreachable: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("leader is unhealthy: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (f *ReplicationFollower) SearchPlayers(query string) []NameMatch {
+	return f.local().SearchPlayers(query)
+}
+
+// WinHistory records when each win reached this follower. After a
+// snapshot, the wins it carried are all dated when it arrived.
+func (f *ReplicationFollower) WinHistory(name string) []time.Time {
+	return f.local().WinHistory(f.lookup(name))
+}
+
+// Profiles and teams are not replicated, so the follower reads and
+// writes them on the leader.
+func (f *ReplicationFollower) GetProfile(name string) (Profile, error) {
+	var profile Profile
+	err := f.callLeader(http.MethodGet, f.playerURL(name)+"/profile", nil, &profile, ErrProfileNotFound, ErrProfilesUnsupported)
+	return profile, err
+}
+
+func (f *ReplicationFollower) SaveProfile(name string, profile Profile) error {
+	return f.callLeader(http.MethodPut, f.playerURL(name)+"/profile", profile, nil, ErrProfileNotFound, ErrProfilesUnsupported)
+}
+
+func (f *ReplicationFollower) DeleteProfile(name string) error {
+	return f.callLeader(http.MethodDelete, f.playerURL(name)+"/profile", nil, nil, ErrProfileNotFound, ErrProfilesUnsupported)
+}
+
+func (f *ReplicationFollower) GetTeam(name string) (Team, error) {
+	var team Team
+	err := f.callLeader(http.MethodGet, f.teamURL(name), nil, &team, ErrTeamNotFound, ErrTeamsUnsupported)
+	return team, err
+}
+
+func (f *ReplicationFollower) GetTeams() ([]Team, error) {
+	var teams []Team
+	err := f.callLeader(http.MethodGet, f.LeaderURL+"/teams", nil, &teams, ErrTeamNotFound, ErrTeamsUnsupported)
+	return teams, err
+}
+
+func (f *ReplicationFollower) SaveTeam(team Team) error {
+	return f.callLeader(http.MethodPut, f.teamURL(team.Name), team, nil, ErrTeamNotFound, ErrTeamsUnsupported)
+}
+
+func (f *ReplicationFollower) RecordTeamWin(name string) error {
+	return f.callLeader(http.MethodPost, f.teamURL(name), nil, nil, ErrTeamNotFound, ErrTeamsUnsupported)
+}
+
+func (f *ReplicationFollower) playerURL(name string) string {
+	return f.LeaderURL + "/players/" + url.PathEscape(name)
+}
+
+func (f *ReplicationFollower) teamURL(name string) string {
+	return f.LeaderURL + "/teams/" + url.PathEscape(name)
+}
+
+func (f *ReplicationFollower) callLeader(method, target string, in, out interface{}, notFound, unsupported error) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return err
+	}
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return notFound
+	case res.StatusCode == http.StatusNotImplemented:
+		return unsupported
+	case res.StatusCode >= 300:
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("leader returned status %d: %s", res.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Applied returns the sequence number of the last event copied from the
+// leader.
+func (f *ReplicationFollower) Applied() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.applied
+}
+
+// Run streams events from the leader until ctx is cancelled, reconnecting
+// from the last applied event whenever the stream breaks.
+func (f *ReplicationFollower) Run(ctx context.Context) {
+	for {
+		if err := f.stream(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("replication stream from %s broke: %v", f.LeaderURL, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.RetryDelay):
+		}
+	}
+}
+
+func (f *ReplicationFollower) stream(ctx context.Context) error {
+	f.mu.Lock()
+	query := url.Values{"from": {strconv.FormatUint(f.applied, 10)}, "epoch": {f.epoch}}
+	f.mu.Unlock()
+	target := f.LeaderURL + replicationPath + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("leader returned status %d", res.StatusCode)
+	}
+
+	// A snapshot carries the whole league on one line, so the stream is
+	// decoded rather than scanned line by line.
+	dec := json.NewDecoder(res.Body)
+	for {
+		var e ReplicationEvent
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("leader closed the stream")
+			}
+			return err
+		}
+		if err := f.apply(e); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *ReplicationFollower) apply(e ReplicationEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e.Epoch != "" {
+		store := NewInMemoryPlayerStore()
+		batch := make([]WinIncrement, len(e.League))
+		for i, p := range e.League {
+			batch[i] = WinIncrement{Name: p.Name, Wins: p.Wins}
+		}
+		if err := store.RecordWins(batch); err != nil {
+			return err
+		}
+		f.store, f.epoch, f.applied = store, e.Epoch, e.Seq
+		return nil
+	}
+	if e.Seq <= f.applied {
+		return nil
+	}
+	if e.Seq != f.applied+1 {
+		return fmt.Errorf("missing events %d to %d", f.applied+1, e.Seq-1)
+	}
+	if e.Into != "" {
+		if err := f.store.MergePlayer(e.Name, e.Into); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < e.Wins; i++ {
+		f.store.RecordWin(e.Name)
+	}
+	f.applied = e.Seq
+	return nil
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReplication(t *testing.T) {
+	t.Run("follower serves the leader's existing league", func(t *testing.T) {
+		leaderStore := NewInMemoryPlayerStore()
+		leaderStore.RecordWin("Pepper")
+		leaderStore.RecordWin("Pepper")
+		leader := httptest.NewServer(NewPlayerServer(NewReplicationLeader(leaderStore)))
+		t.Cleanup(leader.Close)
+
+		follower := startFollower(t, leader.URL)
+
+		waitForScore(t, follower, "Pepper", 2)
+	})
+
+	t.Run("follower takes a snapshot of a large league", func(t *testing.T) {
+		leaderStore := NewInMemoryPlayerStore()
+		batch := make([]WinIncrement, 3000)
+		for i := range batch {
+			batch[i] = WinIncrement{Name: fmt.Sprintf("player-%04d", i), Wins: 1}
+		}
+		assertNoStoreError(t, leaderStore.RecordWins(batch))
+		leader := httptest.NewServer(NewPlayerServer(NewReplicationLeader(leaderStore)))
+		t.Cleanup(leader.Close)
+
+		follower := startFollower(t, leader.URL)
+
+		waitForScore(t, follower, "player-2999", 1)
+		if got := len(follower.GetLeague()); got != len(batch) {
+			t.Errorf("got %d players want %d", got, len(batch))
+		}
+	})
+
+	t.Run("follower forwards wins to the leader and replicates them back", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+
+		follower := startFollower(t, leader.URL)
+		followerServer := httptest.NewServer(NewPlayerServer(follower))
+		t.Cleanup(followerServer.Close)
+
+		res, err := http.Post(followerServer.URL+"/players/Pepper", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		assertResponseCode(t, res.StatusCode, http.StatusAccepted)
+
+		assertScoreEquals(t, leaderStore.GetPlayerScore("Pepper"), 1)
+		waitForScore(t, follower, "Pepper", 1)
+	})
+
+	t.Run("follower forwards batches to the leader", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+		follower := startFollower(t, leader.URL)
+
+		assertNoStoreError(t, RecordWins(follower, []WinIncrement{{"Pepper", 2}, {"Floyd", 1}}))
+
+		assertScoreEquals(t, leaderStore.GetPlayerScore("Pepper"), 2)
+		waitForScore(t, follower, "Pepper", 2)
+		waitForScore(t, follower, "Floyd", 1)
+
+		err := RecordWins(follower, []WinIncrement{{"Pepper", 0}})
+		if err == nil {
+			t.Error("expected the leader to reject an invalid batch")
+		}
+	})
+
+	t.Run("leader only replicates wins its store kept", func(t *testing.T) {
+		wal := newTestWALStore(t, t.TempDir(), WALOptions{})
+		leader := NewReplicationLeader(wal)
+		leader.RecordWin("Pepper")
+		assertNoStoreError(t, wal.Close())
+
+		leader.RecordWin("Pepper")
+
+		if _, events, _ := leader.EventsSince(leader.epoch, 0); len(events) != 1 {
+			t.Errorf("got %d events want 1", len(events))
+		}
+	})
+
+	t.Run("every follower sees wins recorded on the leader", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+
+		first := startFollower(t, leader.URL)
+		second := startFollower(t, leader.URL)
+		leaderStore.RecordWin("Floyd")
+		leaderStore.RecordWin("Floyd")
+
+		waitForScore(t, first, "Floyd", 2)
+		waitForScore(t, second, "Floyd", 2)
+	})
+
+	t.Run("follower catches up after reconnecting", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+
+		follower := NewReplicationFollower(leader.URL)
+		follower.RetryDelay = time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			follower.Run(ctx)
+			close(done)
+		}()
+
+		leaderStore.RecordWin("Pepper")
+		waitForScore(t, follower, "Pepper", 1)
+		cancel()
+		<-done
+
+		leaderStore.RecordWin("Pepper")
+		leaderStore.RecordWin("Floyd")
+		assertScoreEquals(t, follower.GetPlayerScore("Pepper"), 1)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		go follower.Run(ctx)
+
+		waitForScore(t, follower, "Pepper", 2)
+		waitForScore(t, follower, "Floyd", 1)
+		if follower.Applied() != 3 {
+			t.Errorf("got applied sequence %d want 3", follower.Applied())
+		}
+	})
+
+	t.Run("follower reconnects when the leader drops the stream", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+
+		follower := startFollower(t, leader.URL)
+		leaderStore.RecordWin("Pepper")
+		waitForScore(t, follower, "Pepper", 1)
+
+		leader.CloseClientConnections()
+		leaderStore.RecordWin("Pepper")
+
+		waitForScore(t, follower, "Pepper", 2)
+	})
+
+	t.Run("follower resyncs from a snapshot after the leader restarts", func(t *testing.T) {
+		leaderStore := NewInMemoryPlayerStore()
+		leader := NewReplicationLeader(leaderStore)
+		var current atomic.Value
+		current.Store(NewPlayerServer(leader))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current.Load().(*PlayerServer).ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		follower := startFollower(t, server.URL)
+		for i := 0; i < 3; i++ {
+			leader.RecordWin("Alice")
+		}
+		waitForApplied(t, follower, 3)
+
+		// A new leader over the same store numbers its events from 1 again.
+		restarted := NewReplicationLeader(leaderStore)
+		current.Store(NewPlayerServer(restarted))
+		server.CloseClientConnections()
+		restarted.RecordWin("Bob")
+		restarted.RecordWin("Bob")
+		restarted.RecordWin("Carol")
+
+		waitForScore(t, follower, "Bob", 2)
+		waitForScore(t, follower, "Carol", 1)
+		assertScoreEquals(t, follower.GetPlayerScore("Alice"), 3)
+	})
+
+	t.Run("follower behind the compacted log resyncs from a snapshot", func(t *testing.T) {
+		leader := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader.maxEvents = 4
+		for i := 0; i < 10; i++ {
+			leader.RecordWin("Pepper")
+		}
+		if len(leader.events) > leader.maxEvents {
+			t.Fatalf("leader kept %d events, want at most %d", len(leader.events), leader.maxEvents)
+		}
+
+		snapshot, events, _ := leader.EventsSince(leader.epoch, 1)
+		if snapshot == nil || snapshot.Seq != 10 || len(events) != 0 {
+			t.Fatalf("got snapshot %+v and %d events, want a snapshot at 10", snapshot, len(events))
+		}
+		assertLeague(t, snapshot.League, []Player{{Name: "Pepper", Wins: 10}})
+
+		snapshot, events, _ = leader.EventsSince(leader.epoch, 8)
+		if snapshot != nil || len(events) != 2 || events[0].Seq != 9 {
+			t.Errorf("got snapshot %+v and events %+v, want events 9 and 10", snapshot, events)
+		}
+	})
+
+	t.Run("follower reads and writes profiles on the leader", func(t *testing.T) {
+		leaderStore := NewInMemoryPlayerStore()
+		leader := httptest.NewServer(NewPlayerServer(NewReplicationLeader(leaderStore)))
+		t.Cleanup(leader.Close)
+		follower := NewReplicationFollower(leader.URL)
+
+		profile := Profile{DisplayName: "Pep", JoinedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+		assertNoStoreError(t, follower.SaveProfile("Pepper", profile))
+
+		got, err := leaderStore.GetProfile("Pepper")
+		assertNoStoreError(t, err)
+		assertProfile(t, got, profile)
+
+		got, err = follower.GetProfile("Pepper")
+		assertNoStoreError(t, err)
+		assertProfile(t, got, profile)
+
+		assertNoStoreError(t, follower.DeleteProfile("Pepper"))
+		if _, err := follower.GetProfile("Pepper"); err != ErrProfileNotFound {
+			t.Errorf("got error %v, want %v", err, ErrProfileNotFound)
+		}
+	})
+
+	t.Run("team wins on a follower reach every member's score", func(t *testing.T) {
+		leaderStore := NewReplicationLeader(NewInMemoryPlayerStore())
+		leader := httptest.NewServer(NewPlayerServer(leaderStore))
+		t.Cleanup(leader.Close)
+		follower := startFollower(t, leader.URL)
+
+		assertNoStoreError(t, follower.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}}))
+		assertNoStoreError(t, follower.RecordTeamWin("Spicy"))
+
+		waitForScore(t, follower, "Pepper", 1)
+		waitForScore(t, follower, "Chilli", 1)
+		teams, err := follower.GetTeams()
+		assertNoStoreError(t, err)
+		assertTeam(t, teams[0], Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}, Wins: 1})
+		if err := follower.RecordTeamWin("Mild"); err != ErrTeamNotFound {
+			t.Errorf("got error %v, want %v", err, ErrTeamNotFound)
+		}
+	})
+
+	t.Run("stream is not found on a store without replication", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		request, _ := http.NewRequest(http.MethodGet, replicationPath, nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func startFollower(t *testing.T, leaderURL string) *ReplicationFollower {
+	t.Helper()
+	follower := NewReplicationFollower(leaderURL)
+	follower.RetryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		follower.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return follower
+}
+
+func waitForApplied(t *testing.T, follower *ReplicationFollower, want uint64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for follower.Applied() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got applied sequence %d want %d", follower.Applied(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitForScore(t *testing.T, store PlayerStore, name string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for store.GetPlayerScore(name) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got score %d for %s want %d", store.GetPlayerScore(name), name, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchSubstring = "substring"
+	MatchFuzzy     = "fuzzy"
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+
+	// gramEdge marks the start or end of a name in the letter pairs
+	// fuzzy search counts.
+	gramEdge = "\x00"
+)
+
+// NameMatch is a player name that answers a search. Distance is the edit
+// distance for fuzzy matches and zero otherwise.
+type NameMatch struct {
+	Name     string
+	Match    string
+	Distance int
+}
+
+type SearchResult struct {
+	Name     string `json:"name"`
+	Wins     int    `json:"wins"`
+	Match    string `json:"match"`
+	Distance int    `json:"distance,omitempty"`
+}
+
+// PlayerSearcher is implemented by stores that keep a NameIndex, so a
+// search doesn't have to walk the whole league.
+type PlayerSearcher interface {
+	SearchPlayers(query string) []NameMatch
+}
+
+type indexedName struct {
+	key  string
+	name string
+}
+
+// NameIndex finds player names by prefix, substring or edit distance,
+// ignoring case. Names are kept sorted for prefix lookups, and every
+// one, two and three letter run of a name, plus its first and last
+// letters paired with gramEdge, points back at it so substring and
+// fuzzy lookups only look at likely candidates.
+type NameIndex struct {
+	mu     sync.RWMutex
+	sorted []indexedName
+	grams  map[string]map[string]bool
+}
+
+func NewNameIndex() *NameIndex {
+	return &NameIndex{grams: make(map[string]map[string]bool)}
+}
+
+func (x *NameIndex) Add(name string) {
+	key := strings.ToLower(name)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i, found := x.find(key, name)
+	if found {
+		return
+	}
+	x.sorted = append(x.sorted, indexedName{})
+	copy(x.sorted[i+1:], x.sorted[i:])
+	x.sorted[i] = indexedName{key, name}
+	for _, g := range nameGrams(key) {
+		if x.grams[g] == nil {
+			x.grams[g] = make(map[string]bool)
+		}
+		x.grams[g][name] = true
+	}
+}
+
+func (x *NameIndex) Remove(name string) {
+	key := strings.ToLower(name)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i, found := x.find(key, name)
+	if !found {
+		return
+	}
+	x.sorted = append(x.sorted[:i], x.sorted[i+1:]...)
+	for _, g := range nameGrams(key) {
+		delete(x.grams[g], name)
+		if len(x.grams[g]) == 0 {
+			delete(x.grams, g)
+		}
+	}
+}
+
+func (x *NameIndex) find(key, name string) (int, bool) {
+	i := sort.Search(len(x.sorted), func(i int) bool {
+		e := x.sorted[i]
+		return e.key > key || (e.key == key && e.name >= name)
+	})
+	return i, i < len(x.sorted) && x.sorted[i].name == name
+}
+
+// Search returns every name matching query, each under its best kind of
+// match. Fuzzy matches allow one edit for short queries and two for
+// longer ones.
+func (x *NameIndex) Search(query string) []NameMatch {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	matched := make(map[string]bool)
+	var matches []NameMatch
+	add := func(name, kind string, distance int) {
+		if !matched[name] {
+			matched[name] = true
+			matches = append(matches, NameMatch{name, kind, distance})
+		}
+	}
+
+	start := sort.Search(len(x.sorted), func(i int) bool { return x.sorted[i].key >= q })
+	for _, e := range x.sorted[start:] {
+		if !strings.HasPrefix(e.key, q) {
+			break
+		}
+		kind := MatchPrefix
+		if e.key == q {
+			kind = MatchExact
+		}
+		add(e.name, kind, 0)
+	}
+
+	for name := range x.candidates(q) {
+		if strings.Contains(strings.ToLower(name), q) {
+			add(name, MatchSubstring, 0)
+		}
+	}
+
+	// Each edit spoils at most two of q's letter pairs, so a name within
+	// the limit still shares all but 2*limit of them. Only names sharing
+	// at least one pair are counted, which for a one-letter query misses
+	// other single letters.
+	length := utf8.RuneCountInString(q)
+	limit := 1
+	if length > 4 {
+		limit = 2
+	}
+	pairs := edgePairs(q)
+	for name, shared := range x.sharedPairs(pairs) {
+		key := strings.ToLower(name)
+		if matched[name] || shared < len(pairs)-2*limit || abs(utf8.RuneCountInString(key)-length) > limit {
+			continue
+		}
+		if d := levenshtein(q, key); d <= limit {
+			add(name, MatchFuzzy, d)
+		}
+	}
+	return matches
+}
+
+// candidates returns the names containing every three letter run of q,
+// or all of q when it is shorter.
+func (x *NameIndex) candidates(q string) map[string]bool {
+	grams := queryGrams(q, 3)
+	out := make(map[string]bool)
+	for name := range x.grams[grams[0]] {
+		out[name] = true
+	}
+	for _, g := range grams[1:] {
+		for name := range out {
+			if !x.grams[g][name] {
+				delete(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// sharedPairs counts, for every name sharing any of pairs, how many of
+// them it has.
+func (x *NameIndex) sharedPairs(pairs []string) map[string]int {
+	shared := make(map[string]int)
+	for _, p := range pairs {
+		for name := range x.grams[p] {
+			shared[name]++
+		}
+	}
+	return shared
+}
+
+// nameGrams lists every distinct run of one to three letters in key,
+// followed by its edge pairs.
+func nameGrams(key string) []string {
+	runes := []rune(key)
+	seen := make(map[string]bool)
+	var grams []string
+	for n := 1; n <= 3; n++ {
+		for i := 0; i+n <= len(runes); i++ {
+			g := string(runes[i : i+n])
+			if !seen[g] {
+				seen[g] = true
+				grams = append(grams, g)
+			}
+		}
+	}
+	if len(runes) > 0 {
+		grams = append(grams, gramEdge+string(runes[0]), string(runes[len(runes)-1])+gramEdge)
+	}
+	return grams
+}
+
+// edgePairs lists the distinct letter pairs of key with gramEdge at
+// either end, so even a one-letter key has two.
+func edgePairs(key string) []string {
+	runes := []rune(gramEdge + key + gramEdge)
+	seen := make(map[string]bool)
+	var pairs []string
+	for i := 0; i+2 <= len(runes); i++ {
+		p := string(runes[i : i+2])
+		if !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// queryGrams splits q into grams of size letters, or one gram of all of
+// q when it is shorter.
+func queryGrams(q string, size int) []string {
+	runes := []rune(q)
+	n := len(runes)
+	if n > size {
+		n = size
+	}
+	var grams []string
+	for i := 0; i+n <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+n]))
+	}
+	return grams
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// searchPlayers asks the store's index, or builds a throwaway one from
+// the league for stores that don't keep one.
+func searchPlayers(store PlayerStore, query string) []NameMatch {
+	if searcher, ok := store.(PlayerSearcher); ok {
+		return searcher.SearchPlayers(query)
+	}
+	index := NewNameIndex()
+	for _, player := range store.GetLeague() {
+		index.Add(player.Name)
+	}
+	return index.Search(query)
+}
+
+var matchRank = map[string]int{MatchExact: 0, MatchPrefix: 1, MatchSubstring: 2, MatchFuzzy: 3}
+
+// searchHandler serves /players?q=, best matches first and then by wins.
+func (p *PlayerServer) searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	matches := searchPlayers(p.store, query)
+	results := make([]SearchResult, len(matches))
+	for i, m := range matches {
+		results[i] = SearchResult{Name: m.Name, Wins: p.store.GetPlayerScore(m.Name), Match: m.Match, Distance: m.Distance}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if matchRank[a.Match] != matchRank[b.Match] {
+			return matchRank[a.Match] < matchRank[b.Match]
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Name < b.Name
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	writeJSON(w, http.StatusOK, results)
+}
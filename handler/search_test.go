@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNameIndex(t *testing.T) {
+	index := NewNameIndex()
+	for _, name := range []string{"Pepper", "Peppa", "Floyd", "Salt-n-Pepper", "Ed", "Al"} {
+		index.Add(name)
+	}
+
+	cases := []struct {
+		query string
+		want  []NameMatch
+	}{
+		{"pepper", []NameMatch{{"Pepper", MatchExact, 0}, {"Salt-n-Pepper", MatchSubstring, 0}, {"Peppa", MatchFuzzy, 2}}},
+		{"PEP", []NameMatch{{"Peppa", MatchPrefix, 0}, {"Pepper", MatchPrefix, 0}, {"Salt-n-Pepper", MatchSubstring, 0}}},
+		{"floid", []NameMatch{{"Floyd", MatchFuzzy, 1}}},
+		{"e", []NameMatch{{"Ed", MatchPrefix, 0}, {"Pepper", MatchSubstring, 0}, {"Peppa", MatchSubstring, 0}, {"Salt-n-Pepper", MatchSubstring, 0}}},
+		{"xd", []NameMatch{{"Ed", MatchFuzzy, 1}}},
+		{"nobody", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			assertMatches(t, index.Search(c.query), c.want)
+		})
+	}
+
+	t.Run("forgets removed names", func(t *testing.T) {
+		index.Remove("Pepper")
+		assertMatches(t, index.Search("pepper"), []NameMatch{{"Salt-n-Pepper", MatchSubstring, 0}, {"Peppa", MatchFuzzy, 2}})
+	})
+}
+
+func TestLevenshtein(t *testing.T) {
+	cases := map[[2]string]int{
+		{"", "abc"}:           3,
+		{"floyd", "floid"}:    1,
+		{"kitten", "sitting"}: 3,
+		{"amélie", "amelie"}:  1,
+	}
+	for in, want := range cases {
+		if got := levenshtein(in[0], in[1]); got != want {
+			t.Errorf("levenshtein(%q, %q) got %d want %d", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestSearchPlayers(t *testing.T) {
+	stores := map[string]func(t *testing.T) PlayerStore{
+		"memory": func(t *testing.T) PlayerStore { return NewInMemoryPlayerStore() },
+		"wal": func(t *testing.T) PlayerStore {
+			store := newTestWALStore(t, t.TempDir(), WALOptions{})
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"events": func(t *testing.T) PlayerStore { return newTestEventStore(t, NewInMemoryEventLog()) },
+		"stub":   func(t *testing.T) PlayerStore { return &StubPlayerStore{scores: map[string]int{}} },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			server := NewPlayerServer(store)
+			recordWins(server, "Pepper", "Peppa", "Peppa", "Salt-n-Pepper", "Floyd")
+			if stub, ok := store.(*StubPlayerStore); ok {
+				stub.league = []Player{{Name: "Pepper", Wins: 1}, {Name: "Peppa", Wins: 2}, {Name: "Salt-n-Pepper", Wins: 1}, {Name: "Floyd", Wins: 1}}
+				for _, p := range stub.league {
+					stub.scores[p.Name] = p.Wins
+				}
+			}
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, newSearchRequest("/players?q=pep"))
+
+			assertResponseCode(t, response.Code, http.StatusOK)
+			assertSearchResults(t, response.Body.Bytes(), []SearchResult{
+				{Name: "Peppa", Wins: 2, Match: MatchPrefix},
+				{Name: "Pepper", Wins: 1, Match: MatchPrefix},
+				{Name: "Salt-n-Pepper", Wins: 1, Match: MatchSubstring},
+			})
+		})
+	}
+
+	t.Run("finds names merged by a migration under the new name", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Pepper")
+		MigrateNames(store, DefaultNamePolicy)
+
+		assertMatches(t, store.SearchPlayers("pepper"), []NameMatch{{"pepper", MatchExact, 0}})
+	})
+
+	t.Run("limits and validates the query", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		recordWins(server, "Pepper", "Peppa")
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newSearchRequest("/players?q=pep&limit=1"))
+		var results []SearchResult
+		json.NewDecoder(response.Body).Decode(&results)
+		assertScoreEquals(t, len(results), 1)
+
+		for _, path := range []string{"/players", "/players?q=%20", "/players?q=pep&limit=0"} {
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, newSearchRequest(path))
+			assertResponseCode(t, response.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func newSearchRequest(path string) *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, path, nil)
+	return request
+}
+
+func assertMatches(t *testing.T, got, want []NameMatch) {
+	t.Helper()
+	sortMatches(got)
+	sortMatches(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got matches %+v want %+v", got, want)
+	}
+}
+
+func sortMatches(matches []NameMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matchRank[matches[i].Match] != matchRank[matches[j].Match] {
+			return matchRank[matches[i].Match] < matchRank[matches[j].Match]
+		}
+		return matches[i].Name < matches[j].Name
+	})
+}
+
+func assertSearchResults(t *testing.T, body []byte, want []SearchResult) {
+	t.Helper()
+	var got []SearchResult
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("could not parse %q, %v", body, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %+v want %+v", got, want)
+	}
+}
+
+func TestNameIndexFindsEveryFuzzyMatch(t *testing.T) {
+	names := []string{"ab", "ba", "abc", "axc", "xbc", "abx", "abcd", "axcd", "abcde", "axcye", "bcdea", "abcdef", "xbcdyf"}
+	index := NewNameIndex()
+	for _, name := range names {
+		index.Add(name)
+	}
+	for _, q := range names {
+		found := make(map[string]bool)
+		for _, m := range index.Search(q) {
+			found[m.Name] = true
+		}
+		limit := 1
+		if len(q) > 4 {
+			limit = 2
+		}
+		for _, name := range names {
+			if levenshtein(q, name) <= limit && !found[name] {
+				t.Errorf("searching %q missed %q", q, name)
+			}
+		}
+	}
+}
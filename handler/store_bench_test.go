@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchmarkStores opens a fresh store of every backend for each benchmark.
+var benchmarkStores = []struct {
+	name string
+	open func(b *testing.B) PlayerStore
+}{
+	{"memory", func(b *testing.B) PlayerStore { return NewInMemoryPlayerStore() }},
+	{"wal-always", func(b *testing.B) PlayerStore { return openBenchWALStore(b, SyncAlways) }},
+	{"wal-batch", func(b *testing.B) PlayerStore { return openBenchWALStore(b, SyncBatch) }},
+	{"wal-interval", func(b *testing.B) PlayerStore { return openBenchWALStore(b, SyncInterval) }},
+	{"events-memory", func(b *testing.B) PlayerStore {
+		store, err := NewEventSourcedPlayerStore(NewInMemoryEventLog())
+		if err != nil {
+			b.Fatal(err)
+		}
+		return store
+	}},
+	{"events-file", func(b *testing.B) PlayerStore {
+		events, err := NewFileEventLog(filepath.Join(b.TempDir(), "events.log"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		store, err := NewEventSourcedPlayerStore(events)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { store.Close() })
+		return store
+	}},
+}
+
+func BenchmarkRecordWin(b *testing.B) {
+	for _, backend := range benchmarkStores {
+		b.Run(backend.name, func(b *testing.B) {
+			store := backend.open(b)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.RecordWin(benchPlayer(i))
+			}
+		})
+	}
+}
+
+func BenchmarkGetPlayerScore(b *testing.B) {
+	for _, backend := range benchmarkStores {
+		b.Run(backend.name, func(b *testing.B) {
+			store := seededBenchStore(b, backend.open)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.GetPlayerScore(benchPlayer(i))
+			}
+		})
+	}
+}
+
+func BenchmarkGetLeague(b *testing.B) {
+	for _, backend := range benchmarkStores {
+		b.Run(backend.name, func(b *testing.B) {
+			store := seededBenchStore(b, backend.open)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.GetLeague()
+			}
+		})
+	}
+}
+
+// BenchmarkPlayerServer runs the default request mix through the
+// server so the numbers include routing and encoding.
+func BenchmarkPlayerServer(b *testing.B) {
+	for _, backend := range benchmarkStores {
+		b.Run(backend.name, func(b *testing.B) {
+			g := &LoadGenerator{Handler: NewPlayerServer(backend.open(b)), Workers: 8, Requests: b.N}
+			b.ResetTimer()
+			report, err := g.Run(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(report.WinsPerSecond, "wins/s")
+			b.ReportMetric(float64(report.P99.Microseconds()), "p99-µs")
+		})
+	}
+}
+
+func openBenchWALStore(b *testing.B, sync SyncPolicy) PlayerStore {
+	store, err := NewWALPlayerStore(b.TempDir(), WALOptions{Sync: sync})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { store.Close() })
+	return store
+}
+
+func seededBenchStore(b *testing.B, open func(b *testing.B) PlayerStore) PlayerStore {
+	store := open(b)
+	for i := 0; i < 1000; i++ {
+		store.RecordWin(benchPlayer(i))
+	}
+	return store
+}
+
+var benchPlayers = func() []string {
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = benchPlayer(i)
+	}
+	return names
+}()
+
+func benchPlayer(i int) string {
+	return fmt.Sprintf("player-%d", i%100)
+}
+
+// mutexPlayerStore is the single-lock design the sharded store replaced,
+// kept so the parallel benchmarks have something to compare against.
+type mutexPlayerStore struct {
+	mu      sync.Mutex
+	scores  map[string]int
+	history map[string][]time.Time
+}
+
+func (s *mutexPlayerStore) GetPlayerScore(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scores[name]
+}
+
+func (s *mutexPlayerStore) RecordWin(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scores[name]++
+	s.history[name] = append(s.history[name], time.Now().UTC())
+}
+
+func (s *mutexPlayerStore) GetLeague() []Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	players := make([]Player, 0, len(s.scores))
+	for k, v := range s.scores {
+		players = append(players, Player{Name: k, Wins: v})
+	}
+	return players
+}
+
+// BenchmarkInMemoryParallel compares the stores under parallel load
+// with one write in every writeEvery operations. Run it with -cpu 1,2,4,8
+// to see how each scales.
+func BenchmarkInMemoryParallel(b *testing.B) {
+	stores := []struct {
+		name string
+		open func() PlayerStore
+	}{
+		{"sharded", func() PlayerStore { return NewInMemoryPlayerStore() }},
+		{"single-mutex", func() PlayerStore {
+			return &mutexPlayerStore{scores: make(map[string]int), history: make(map[string][]time.Time)}
+		}},
+	}
+	for _, writeEvery := range []int{1, 10, 100} {
+		for _, s := range stores {
+			b.Run(fmt.Sprintf("%s/write-1-in-%d", s.name, writeEvery), func(b *testing.B) {
+				store := s.open()
+				for i := 0; i < 1000; i++ {
+					store.RecordWin(benchPlayer(i))
+				}
+				var next atomic.Int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						i := int(next.Add(1))
+						name := benchPlayers[i%len(benchPlayers)]
+						if i%writeEvery == 0 {
+							store.RecordWin(name)
+						} else {
+							store.GetPlayerScore(name)
+						}
+					}
+				})
+			})
+		}
+	}
+}
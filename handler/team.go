@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const maxTeamMembers = 16
+
+var (
+	ErrTeamNotFound     = errors.New("team not found")
+	ErrTeamsUnsupported = errors.New("player store does not support teams")
+)
+
+// TeamStore keeps teams alongside players. SaveTeam replaces the members
+// but keeps the wins already stored, which only RecordTeamWin changes; it
+// credits the team and every member in one step.
+type TeamStore interface {
+	GetTeam(name string) (Team, error)
+	GetTeams() ([]Team, error)
+	SaveTeam(team Team) error
+	RecordTeamWin(name string) error
+}
+
+type TeamStanding struct {
+	Name       string
+	Members    []string
+	Wins       int
+	MemberWins int
+}
+
+func (t Team) Validate() error {
+	if t.Name == "" || strings.Contains(t.Name, "/") {
+		return errors.New("team name must be non-empty and must not contain '/'")
+	}
+	if len(t.Members) == 0 || len(t.Members) > maxTeamMembers {
+		return fmt.Errorf("a team needs between 1 and %d members", maxTeamMembers)
+	}
+	seen := make(map[string]bool)
+	for _, m := range t.Members {
+		if m == "" {
+			return errors.New("member names must be non-empty")
+		}
+		if seen[m] {
+			return fmt.Errorf("%q is listed twice", m)
+		}
+		seen[m] = true
+	}
+	return nil
+}
+
+func (t Team) clone() Team {
+	t.Members = append([]string(nil), t.Members...)
+	return t
+}
+
+func (p *PlayerServer) teamsHandler(w http.ResponseWriter, r *http.Request) {
+	teams, ok := p.store.(TeamStore)
+	if !ok {
+		http.Error(w, ErrTeamsUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/teams"), "/")
+	if path == "" {
+		p.listTeams(w, r, teams)
+		return
+	}
+
+	name, resource, _ := strings.Cut(path, "/")
+	switch {
+	case resource == "league" && r.Method == http.MethodGet:
+		p.teamLeague(w, teams, name)
+	case resource != "":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodGet:
+		team, err := teams.GetTeam(name)
+		if err != nil {
+			writeTeamError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, team)
+	case r.Method == http.MethodPut:
+		p.saveTeam(w, r, teams, name)
+	case r.Method == http.MethodPost:
+		p.recordTeamWin(w, teams, name)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *PlayerServer) recordTeamWin(w http.ResponseWriter, teams TeamStore, name string) {
+	team, err := teams.GetTeam(name)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	rivals, before := p.rivalWins(team.Members...), p.scoresBefore(team.Members...)
+	if err := teams.RecordTeamWin(name); err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	p.updatePodium(team.Members...)
+	p.awardAchievements(before, rivals, team.Members...)
+	p.publishWins(before, team.Members...)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (p *PlayerServer) listTeams(w http.ResponseWriter, r *http.Request, teams TeamStore) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	all, err := teams.GetTeams()
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	standings := make([]TeamStanding, 0, len(all))
+	for _, team := range all {
+		standing := TeamStanding{Name: team.Name, Members: team.Members, Wins: team.Wins}
+		for _, m := range team.Members {
+			standing.MemberWins += p.store.GetPlayerScore(m)
+		}
+		standings = append(standings, standing)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.MemberWins != b.MemberWins {
+			return a.MemberWins > b.MemberWins
+		}
+		return a.Name < b.Name
+	})
+	writeJSON(w, http.StatusOK, standings)
+}
+
+func (p *PlayerServer) teamLeague(w http.ResponseWriter, teams TeamStore, name string) {
+	team, err := teams.GetTeam(name)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	league := make([]Player, 0, len(team.Members))
+	for _, m := range team.Members {
+		league = append(league, Player{Name: m, Wins: p.store.GetPlayerScore(m)})
+	}
+	sort.SliceStable(league, func(i, j int) bool {
+		return league[i].Wins > league[j].Wins
+	})
+	writeJSON(w, http.StatusOK, league)
+}
+
+func (p *PlayerServer) saveTeam(w http.ResponseWriter, r *http.Request, teams TeamStore, name string) {
+	var team Team
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&team); err != nil {
+		http.Error(w, "invalid team: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if team.Name != "" && team.Name != name {
+		http.Error(w, "invalid team: name does not match the URL", http.StatusBadRequest)
+		return
+	}
+	team.Name = name
+	members, err := p.playerNames(team.Members)
+	if err != nil {
+		http.Error(w, "invalid team: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	team.Members = members
+	if err := team.Validate(); err != nil {
+		http.Error(w, "invalid team: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if _, err := teams.GetTeam(name); errors.Is(err, ErrTeamNotFound) {
+		status = http.StatusCreated
+	} else if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	if err := teams.SaveTeam(team); err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	if saved, err := teams.GetTeam(name); err == nil {
+		team = saved
+	}
+	writeJSON(w, status, team)
+}
+
+func writeTeamError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrTeamNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrTeamsUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
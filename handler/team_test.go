@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTeams(t *testing.T) {
+	t.Run("creates a team and credits every member on a team win", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPutTeamRequest("Spicy", `{"Members":["Pepper","Chilli"]}`))
+		assertResponseCode(t, response.Code, http.StatusCreated)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newPostTeamWinRequest("Spicy"))
+		assertResponseCode(t, response.Code, http.StatusAccepted)
+
+		checkFoundWithBody(t, server, "Pepper", "1")
+		checkFoundWithBody(t, server, "Chilli", "1")
+
+		team, _ := store.GetTeam("Spicy")
+		if team.Wins != 1 {
+			t.Errorf("got %d team wins want 1", team.Wins)
+		}
+	})
+
+	t.Run("replacing members keeps the team's wins", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper"}})
+		for i := 0; i < 4; i++ {
+			store.RecordTeamWin("Spicy")
+		}
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPutTeamRequest("Spicy", `{"Members":["Pepper","Chilli"],"Wins":100}`))
+		assertResponseCode(t, response.Code, http.StatusOK)
+
+		team, _ := store.GetTeam("Spicy")
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}, Wins: 4})
+	})
+
+	t.Run("stores ignore the wins they are given", func(t *testing.T) {
+		wal := newTestWALStore(t, t.TempDir(), WALOptions{})
+		defer wal.Close()
+		stores := map[string]TeamStore{
+			"memory": NewInMemoryPlayerStore(),
+			"wal":    wal,
+			"events": newTestEventStore(t, NewInMemoryEventLog()),
+		}
+		for name, store := range stores {
+			t.Run(name, func(t *testing.T) {
+				assertNoStoreError(t, store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper"}, Wins: 100}))
+				assertNoStoreError(t, store.RecordTeamWin("Spicy"))
+				assertNoStoreError(t, store.SaveTeam(Team{Name: "Spicy", Members: []string{"Chilli"}}))
+
+				team, _ := store.GetTeam("Spicy")
+				assertTeam(t, team, Team{Name: "Spicy", Members: []string{"Chilli"}, Wins: 1})
+			})
+		}
+	})
+
+	t.Run("rejects invalid teams", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		bodies := map[string]string{
+			"no members":        `{"Members":[]}`,
+			"duplicate members": `{"Members":["Pepper","Pepper"]}`,
+			"empty member":      `{"Members":[""]}`,
+			"mismatched name":   `{"Name":"Mild","Members":["Pepper"]}`,
+			"unknown field":     `{"Members":["Pepper"],"Captain":"Pepper"}`,
+		}
+
+		for title, body := range bodies {
+			t.Run(title, func(t *testing.T) {
+				response := httptest.NewRecorder()
+				server.ServeHTTP(response, newPutTeamRequest("Spicy", body))
+				assertResponseCode(t, response.Code, http.StatusBadRequest)
+			})
+		}
+	})
+
+	t.Run("returns 404 for unknown teams", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+
+		for _, request := range []*http.Request{
+			newGetTeamRequest("/teams/Spicy"),
+			newGetTeamRequest("/teams/Spicy/league"),
+			newPostTeamWinRequest("Spicy"),
+		} {
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+			assertResponseCode(t, response.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("lists team standings", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.SaveTeam(Team{Name: "Mild", Members: []string{"Cleo"}})
+		store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}})
+		store.SaveTeam(Team{Name: "Sweet", Members: []string{"Honey"}})
+		store.RecordTeamWin("Spicy")
+		store.RecordWin("Cleo")
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetTeamRequest("/teams"))
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+
+		var got []TeamStanding
+		json.NewDecoder(response.Body).Decode(&got)
+		want := []TeamStanding{
+			{Name: "Spicy", Members: []string{"Pepper", "Chilli"}, Wins: 1, MemberWins: 2},
+			{Name: "Mild", Members: []string{"Cleo"}, Wins: 0, MemberWins: 1},
+			{Name: "Sweet", Members: []string{"Honey"}, Wins: 0, MemberWins: 0},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("returns a team's league of members", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}})
+		store.RecordWin("Chilli")
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetTeamRequest("/teams/Spicy/league"))
+		assertResponseCode(t, response.Code, http.StatusOK)
+
+		want := []Player{{Name: "Chilli", Wins: 1}, {Name: "Pepper", Wins: 0}}
+		assertLeague(t, getLeagueFromResponse(t, response.Body), want)
+	})
+
+	t.Run("returns 501 when the store has no teams", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newGetTeamRequest("/teams"))
+
+		assertResponseCode(t, response.Code, http.StatusNotImplemented)
+	})
+}
+
+func newPutTeamRequest(name, body string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPut, "/teams/"+name, strings.NewReader(body))
+	return request
+}
+
+func newPostTeamWinRequest(name string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, "/teams/"+name, nil)
+	return request
+}
+
+func newGetTeamRequest(path string) *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, path, nil)
+	return request
+}
+
+func assertTeam(t *testing.T, got, want Team) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got team %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// TLSOptions configures HTTPS. With SelfSigned set and no certificate
+// on disk yet, a development certificate for Hosts is generated, and
+// saved to CertFile and KeyFile when they are given so it survives
+// restarts. ClientCAFile turns on mutual TLS: clients must present a
+// certificate signed by one of its CAs.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	SelfSigned   bool
+	Hosts        []string
+	ClientCAFile string
+}
+
+// Enabled reports whether any option asks for TLS.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.SelfSigned || o.ClientCAFile != ""
+}
+
+// Validate checks the options describe a certificate the server can use:
+// a certificate and key given together, or a self-signed one.
+func (o TLSOptions) Validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	if o.CertFile == "" && !o.SelfSigned {
+		return errors.New("TLS needs a certificate and key, or a self-signed certificate")
+	}
+	return nil
+}
+
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	cert, err := loadOrCreateCert(opts)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if opts.ClientCAFile != "" {
+		data, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func loadOrCreateCert(opts TLSOptions) (tls.Certificate, error) {
+	haveFiles := opts.CertFile != "" && opts.KeyFile != ""
+	if haveFiles {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err == nil || !opts.SelfSigned || !errors.Is(err, os.ErrNotExist) {
+			return cert, err
+		}
+	}
+	certPEM, keyPEM, err := GenerateSelfSignedCert(opts.Hosts, selfSignedValidity)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if haveFiles {
+		if err := os.WriteFile(opts.CertFile, certPEM, 0o644); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.WriteFile(opts.KeyFile, keyPEM, 0o600); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// GenerateSelfSignedCert returns a PEM certificate and key for hosts,
+// which may be names or IP addresses and default to localhost. It is
+// meant for development: the certificate can sign itself, so it can
+// also be handed to a server as the CA for a test client.
+func GenerateSelfSignedCert(hosts []string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Player server development"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// UseTLS makes the server speak HTTPS, offering HTTP/2 to clients that
+// support it.
+func (s *Server) UseTLS(config *tls.Config) {
+	s.HTTP.TLSConfig = config
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	s.HTTP.Protocols = protocols
+}
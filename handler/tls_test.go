@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLS(t *testing.T) {
+	t.Run("serves the league over HTTPS", func(t *testing.T) {
+		server := httptest.NewTLSServer(NewPlayerServer(NewInMemoryPlayerStore()))
+		defer server.Close()
+
+		res, err := server.Client().Get(server.URL + "/league")
+
+		assertNoStoreError(t, err)
+		res.Body.Close()
+		assertResponseCode(t, res.StatusCode, http.StatusOK)
+	})
+
+	t.Run("negotiates HTTP/2", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(NewPlayerServer(NewInMemoryPlayerStore()))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		res, err := server.Client().Get(server.URL + "/league")
+
+		assertNoStoreError(t, err)
+		res.Body.Close()
+		assertProto(t, res, 2)
+	})
+
+	t.Run("generates a self-signed certificate once and reuses it", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := TLSOptions{
+			CertFile:   filepath.Join(dir, "cert.pem"),
+			KeyFile:    filepath.Join(dir, "key.pem"),
+			SelfSigned: true,
+		}
+		config, err := NewTLSConfig(opts)
+		assertNoStoreError(t, err)
+		certPEM, _ := os.ReadFile(opts.CertFile)
+
+		_, err = NewTLSConfig(opts)
+		assertNoStoreError(t, err)
+		again, _ := os.ReadFile(opts.CertFile)
+		if !bytes.Equal(certPEM, again) {
+			t.Error("expected the saved certificate to be reused")
+		}
+		if info, _ := os.Stat(opts.KeyFile); info.Mode().Perm() != 0o600 {
+			t.Errorf("key file has mode %v", info.Mode().Perm())
+		}
+
+		url := startTLSTestServer(t, config)
+		client := newTLSClient(t, certPEM, nil)
+		res, err := client.Get(url + "/league")
+		assertNoStoreError(t, err)
+		res.Body.Close()
+		assertProto(t, res, 2)
+	})
+
+	t.Run("requires client certificates with mutual TLS", func(t *testing.T) {
+		dir := t.TempDir()
+		serverCert, serverKey, err := GenerateSelfSignedCert(nil, selfSignedValidity)
+		assertNoStoreError(t, err)
+		clientCert, clientKey, err := GenerateSelfSignedCert([]string{"scorekeeper"}, selfSignedValidity)
+		assertNoStoreError(t, err)
+		opts := TLSOptions{
+			CertFile:     writeTestFile(t, dir, "server.pem", serverCert),
+			KeyFile:      writeTestFile(t, dir, "server-key.pem", serverKey),
+			ClientCAFile: writeTestFile(t, dir, "clients.pem", clientCert),
+		}
+		config, err := NewTLSConfig(opts)
+		assertNoStoreError(t, err)
+		url := startTLSTestServer(t, config)
+
+		if res, err := newTLSClient(t, serverCert, nil).Get(url + "/league"); err == nil {
+			res.Body.Close()
+			t.Fatal("expected a client without a certificate to be refused")
+		}
+
+		pair, err := tls.X509KeyPair(clientCert, clientKey)
+		assertNoStoreError(t, err)
+		res, err := newTLSClient(t, serverCert, &pair).Get(url + "/league")
+		assertNoStoreError(t, err)
+		res.Body.Close()
+		assertResponseCode(t, res.StatusCode, http.StatusOK)
+	})
+
+	t.Run("needs a certificate unless self-signing", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := NewTLSConfig(TLSOptions{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")})
+		if err == nil {
+			t.Error("expected an error for missing certificate files")
+		}
+		if _, err := NewTLSConfig(TLSOptions{}); err == nil {
+			t.Error("expected an error without any certificate")
+		}
+	})
+
+	t.Run("refuses options without a usable certificate", func(t *testing.T) {
+		cases := map[string]TLSOptions{
+			"client CA alone":           {ClientCAFile: "ca.pem"},
+			"key without a certificate": {KeyFile: "key.pem"},
+			"certificate without a key": {CertFile: "cert.pem", SelfSigned: true},
+		}
+		for name, opts := range cases {
+			t.Run(name, func(t *testing.T) {
+				if !opts.Enabled() {
+					t.Error("options don't count as asking for TLS")
+				}
+				if err := opts.Validate(); err == nil {
+					t.Error("expected an error")
+				}
+			})
+		}
+		if (TLSOptions{}).Enabled() {
+			t.Error("empty options ask for TLS")
+		}
+	})
+}
+
+func startTLSTestServer(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(listener.Addr().String(), NewPlayerServer(NewInMemoryPlayerStore()))
+	server.UseTLS(config)
+	done := make(chan error)
+	go func() { done <- server.Serve(listener) }()
+	t.Cleanup(func() {
+		server.HTTP.Close()
+		if err := <-done; err != nil {
+			t.Errorf("server stopped with %v", err)
+		}
+	})
+	return "https://" + listener.Addr().String()
+}
+
+func newTLSClient(t *testing.T, caPEM []byte, cert *tls.Certificate) *http.Client {
+	t.Helper()
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatal("could not parse CA certificate")
+	}
+	config := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	transport := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	t.Cleanup(transport.CloseIdleConnections)
+	return &http.Client{Transport: transport}
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func assertProto(t *testing.T, res *http.Response, major int) {
+	t.Helper()
+	if res.ProtoMajor != major {
+		t.Errorf("got protocol %s want HTTP/%d", res.Proto, major)
+	}
+}
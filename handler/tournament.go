@@ -0,0 +1,484 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type TournamentFormat string
+
+const (
+	SingleElimination TournamentFormat = "single-elimination"
+	DoubleElimination TournamentFormat = "double-elimination"
+	RoundRobin        TournamentFormat = "round-robin"
+)
+
+const (
+	winnersBracket = "winners"
+	losersBracket  = "losers"
+	grandFinal     = "final"
+	roundRobin     = "round-robin"
+)
+
+var (
+	ErrTournamentNotFound = errors.New("tournament not found")
+	ErrTournamentExists   = errors.New("tournament already exists")
+	ErrMatchNotFound      = errors.New("match not found")
+	ErrMatchNotReady      = errors.New("match is waiting for its players")
+	ErrMatchPlayed        = errors.New("match has already been played")
+	ErrNotInMatch         = errors.New("winner is not playing in this match")
+)
+
+// Match is one game in a bracket. A slot left empty once the match is
+// ready is a bye, and the other player goes through without playing.
+type Match struct {
+	ID      int       `json:"id"`
+	Bracket string    `json:"bracket"`
+	Round   int       `json:"round"`
+	Players [2]string `json:"players"`
+	Winner  string    `json:"winner,omitempty"`
+	Done    bool      `json:"done"`
+	Bye     bool      `json:"bye,omitempty"`
+
+	filled [2]bool
+	winTo  matchSlot
+	loseTo matchSlot
+}
+
+type matchSlot struct {
+	match *Match
+	index int
+}
+
+type Tournament struct {
+	Name      string           `json:"name"`
+	Format    TournamentFormat `json:"format"`
+	Players   []string         `json:"players"`
+	Matches   []*Match         `json:"matches"`
+	Standings []Player         `json:"standings,omitempty"`
+	Champion  string           `json:"champion,omitempty"`
+
+	final *Match
+}
+
+func NewTournament(name string, format TournamentFormat, players []string) (*Tournament, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, errors.New("tournament name must be non-empty and must not contain '/'")
+	}
+	if len(players) < 2 {
+		return nil, errors.New("a tournament needs at least two players")
+	}
+	seen := make(map[string]bool)
+	for _, p := range players {
+		if p == "" || seen[p] {
+			return nil, fmt.Errorf("player names must be non-empty and unique, got %q", p)
+		}
+		seen[p] = true
+	}
+
+	t := &Tournament{
+		Name:    name,
+		Format:  format,
+		Players: append([]string(nil), players...),
+	}
+	switch format {
+	case SingleElimination:
+		t.buildElimination(false)
+	case DoubleElimination:
+		t.buildElimination(true)
+	case RoundRobin:
+		t.buildRoundRobin()
+	default:
+		return nil, fmt.Errorf("unknown tournament format %q", format)
+	}
+	return t, nil
+}
+
+// RecordResult marks winner as the winner of the match and moves both
+// players on through the bracket.
+func (t *Tournament) RecordResult(matchID int, winner string) error {
+	if matchID < 1 || matchID > len(t.Matches) {
+		return ErrMatchNotFound
+	}
+	m := t.Matches[matchID-1]
+	switch {
+	case m.Done:
+		return ErrMatchPlayed
+	case !m.filled[0] || !m.filled[1]:
+		return ErrMatchNotReady
+	case winner != m.Players[0] && winner != m.Players[1]:
+		return ErrNotInMatch
+	}
+
+	loser := m.Players[0]
+	if winner == loser {
+		loser = m.Players[1]
+	}
+	t.finish(m, winner, loser)
+	if t.Format == RoundRobin {
+		t.updateStandings()
+	}
+	return nil
+}
+
+func (t *Tournament) buildElimination(double bool) {
+	size := 2
+	rounds := 1
+	for size < len(t.Players) {
+		size *= 2
+		rounds++
+	}
+
+	winners := make([][]*Match, rounds+1)
+	for r := 1; r <= rounds; r++ {
+		winners[r] = t.addRound(winnersBracket, r, size>>r)
+	}
+	for r := 1; r < rounds; r++ {
+		for i, m := range winners[r] {
+			m.winTo = matchSlot{winners[r+1][i/2], i % 2}
+		}
+	}
+	t.final = winners[rounds][0]
+
+	if double {
+		t.buildLosersBracket(winners, size, rounds)
+	}
+
+	// Seed so the strongest players meet as late as possible, then let
+	// byes resolve themselves.
+	for i, seed := range seedOrder(size) {
+		player := ""
+		if seed <= len(t.Players) {
+			player = t.Players[seed-1]
+		}
+		t.fill(matchSlot{winners[1][i/2], i % 2}, player)
+	}
+}
+
+func (t *Tournament) buildLosersBracket(winners [][]*Match, size, rounds int) {
+	var losers [][]*Match
+	if rounds > 1 {
+		losers = make([][]*Match, 2*rounds-1)
+		losers[1] = t.addRound(losersBracket, 1, size/4)
+		for i, m := range winners[1] {
+			m.loseTo = matchSlot{losers[1][i/2], i % 2}
+		}
+		for j := 1; j < rounds; j++ {
+			// Even rounds meet the losers dropping out of the next
+			// winners round.
+			even := 2 * j
+			losers[even] = t.addRound(losersBracket, even, size>>(j+1))
+			for i, m := range losers[even-1] {
+				m.winTo = matchSlot{losers[even][i], 0}
+			}
+			for i, m := range winners[j+1] {
+				m.loseTo = matchSlot{losers[even][i], 1}
+			}
+			if j == rounds-1 {
+				break
+			}
+			// Odd rounds halve the survivors.
+			odd := even + 1
+			losers[odd] = t.addRound(losersBracket, odd, size>>(j+2))
+			for i, m := range losers[even] {
+				m.winTo = matchSlot{losers[odd][i/2], i % 2}
+			}
+		}
+	}
+
+	final := t.addRound(grandFinal, 1, 1)[0]
+	winners[rounds][0].winTo = matchSlot{final, 0}
+	if rounds == 1 {
+		winners[1][0].loseTo = matchSlot{final, 1}
+	} else {
+		losers[len(losers)-1][0].winTo = matchSlot{final, 1}
+	}
+	t.final = final
+}
+
+func (t *Tournament) buildRoundRobin() {
+	players := append([]string(nil), t.Players...)
+	if len(players)%2 == 1 {
+		players = append(players, "")
+	}
+	n := len(players)
+	for r := 1; r < n; r++ {
+		for i := 0; i < n/2; i++ {
+			a, b := players[i], players[n-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			m := t.addMatch(roundRobin, r)
+			m.Players = [2]string{a, b}
+			m.filled = [2]bool{true, true}
+		}
+		// Keep the first player fixed and rotate everyone else.
+		last := players[n-1]
+		copy(players[2:], players[1:n-1])
+		players[1] = last
+	}
+	t.updateStandings()
+}
+
+func (t *Tournament) addRound(bracket string, round, matches int) []*Match {
+	ms := make([]*Match, matches)
+	for i := range ms {
+		ms[i] = t.addMatch(bracket, round)
+	}
+	return ms
+}
+
+func (t *Tournament) addMatch(bracket string, round int) *Match {
+	m := &Match{ID: len(t.Matches) + 1, Bracket: bracket, Round: round}
+	t.Matches = append(t.Matches, m)
+	return m
+}
+
+func (t *Tournament) fill(s matchSlot, player string) {
+	if s.match == nil {
+		return
+	}
+	m := s.match
+	m.Players[s.index] = player
+	m.filled[s.index] = true
+	if !m.filled[0] || !m.filled[1] || (m.Players[0] != "" && m.Players[1] != "") {
+		return
+	}
+
+	m.Bye = true
+	winner := m.Players[0]
+	if winner == "" {
+		winner = m.Players[1]
+	}
+	t.finish(m, winner, "")
+}
+
+func (t *Tournament) finish(m *Match, winner, loser string) {
+	m.Winner = winner
+	m.Done = true
+	if m == t.final && t.needsReset(m, winner) {
+		t.addReset(m)
+	} else if m == t.final {
+		t.Champion = winner
+	}
+	t.fill(m.winTo, winner)
+	t.fill(m.loseTo, loser)
+}
+
+// needsReset reports whether the grand final has to be played again:
+// when the player from the losers bracket wins it, the winners bracket
+// player has only lost once.
+func (t *Tournament) needsReset(m *Match, winner string) bool {
+	return t.Format == DoubleElimination && m.Round == 1 && !m.Bye && winner == m.Players[1]
+}
+
+// addReset adds the deciding second grand final between the same two
+// players.
+func (t *Tournament) addReset(first *Match) {
+	reset := t.addMatch(grandFinal, 2)
+	reset.Players = first.Players
+	reset.filled = [2]bool{true, true}
+	t.final = reset
+}
+
+func (t *Tournament) updateStandings() {
+	wins := make(map[string]int)
+	played := 0
+	for _, m := range t.Matches {
+		if m.Done {
+			wins[m.Winner]++
+			played++
+		}
+	}
+	t.Standings = make([]Player, len(t.Players))
+	for i, p := range t.Players {
+		t.Standings[i] = Player{Name: p, Wins: wins[p]}
+	}
+	sort.SliceStable(t.Standings, func(i, j int) bool {
+		return t.Standings[i].Wins > t.Standings[j].Wins
+	})
+	if played == len(t.Matches) {
+		t.Champion = t.Standings[0].Name
+	}
+}
+
+// seedOrder lists which seed plays in each first round slot, e.g. for
+// four slots 1 v 4 and 2 v 3.
+func seedOrder(size int) []int {
+	order := []int{1, 2}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		for _, s := range order {
+			next = append(next, s, len(order)*2+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+type TournamentRegistry struct {
+	mu          sync.Mutex
+	tournaments map[string]*Tournament
+}
+
+func NewTournamentRegistry() *TournamentRegistry {
+	return &TournamentRegistry{tournaments: make(map[string]*Tournament)}
+}
+
+func (r *TournamentRegistry) Create(name string, format TournamentFormat, players []string) (*Tournament, error) {
+	t, err := NewTournament(name, format, players)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tournaments[name]; ok {
+		return nil, ErrTournamentExists
+	}
+	r.tournaments[name] = t
+	return t, nil
+}
+
+// Get returns the tournament encoded as JSON so callers never share the
+// bracket with concurrent updates.
+func (r *TournamentRegistry) Get(name string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tournaments[name]
+	if !ok {
+		return nil, ErrTournamentNotFound
+	}
+	return json.Marshal(t)
+}
+
+func (r *TournamentRegistry) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.tournaments))
+	for name := range r.tournaments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *TournamentRegistry) RecordResult(name string, matchID int, winner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tournaments[name]
+	if !ok {
+		return ErrTournamentNotFound
+	}
+	return t.RecordResult(matchID, winner)
+}
+
+type newTournamentRequest struct {
+	Name    string           `json:"name"`
+	Format  TournamentFormat `json:"format"`
+	Players []string         `json:"players"`
+}
+
+type matchResultRequest struct {
+	Winner string `json:"winner"`
+}
+
+func (p *PlayerServer) tournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/tournaments"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, p.tournaments.List())
+		case http.MethodPost:
+			p.createTournament(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		p.showTournament(w, http.StatusOK, parts[0])
+	case len(parts) == 3 && parts[1] == "matches" && r.Method == http.MethodPost:
+		p.recordMatch(w, r, parts[0], parts[2])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *PlayerServer) createTournament(w http.ResponseWriter, r *http.Request) {
+	var req newTournamentRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid tournament: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	players, err := p.playerNames(req.Players)
+	if err != nil {
+		http.Error(w, "invalid tournament: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err = p.tournaments.Create(req.Name, req.Format, players)
+	switch {
+	case errors.Is(err, ErrTournamentExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "invalid tournament: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.showTournament(w, http.StatusCreated, req.Name)
+}
+
+func (p *PlayerServer) recordMatch(w http.ResponseWriter, r *http.Request, name, id string) {
+	matchID, err := strconv.Atoi(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var req matchResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Winner, err = p.playerName(req.Winner); err != nil {
+		http.Error(w, "invalid result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = p.tournaments.RecordResult(name, matchID, req.Winner)
+	switch {
+	case errors.Is(err, ErrTournamentNotFound), errors.Is(err, ErrMatchNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotInMatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	p.recordWin(req.Winner)
+	p.showTournament(w, http.StatusOK, name)
+}
+
+func (p *PlayerServer) showTournament(w http.ResponseWriter, status int, name string) {
+	data, err := p.tournaments.Get(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
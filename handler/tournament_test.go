@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSingleElimination(t *testing.T) {
+	tournament, err := NewTournament("Monthly", SingleElimination, []string{"Cleo", "Chris", "Tiest"})
+	assertNoStoreError(t, err)
+
+	if len(tournament.Matches) != 3 {
+		t.Fatalf("got %d matches want 3", len(tournament.Matches))
+	}
+	first := tournament.Matches[0]
+	if !first.Bye || first.Winner != "Cleo" {
+		t.Errorf("expected the top seed to get a bye, got %+v", first)
+	}
+	assertMatchPlayers(t, tournament.Matches[2], [2]string{"Cleo", ""})
+
+	t.Run("rejects results for matches that are not ready", func(t *testing.T) {
+		assertTournamentError(t, tournament.RecordResult(3, "Cleo"), ErrMatchNotReady)
+	})
+
+	t.Run("rejects winners who are not in the match", func(t *testing.T) {
+		assertTournamentError(t, tournament.RecordResult(2, "Cleo"), ErrNotInMatch)
+	})
+
+	t.Run("rejects unknown matches", func(t *testing.T) {
+		assertTournamentError(t, tournament.RecordResult(9, "Cleo"), ErrMatchNotFound)
+	})
+
+	t.Run("advances winners to the final", func(t *testing.T) {
+		assertNoStoreError(t, tournament.RecordResult(2, "Tiest"))
+		assertMatchPlayers(t, tournament.Matches[2], [2]string{"Cleo", "Tiest"})
+		assertTournamentError(t, tournament.RecordResult(2, "Tiest"), ErrMatchPlayed)
+
+		assertNoStoreError(t, tournament.RecordResult(3, "Tiest"))
+		if tournament.Champion != "Tiest" {
+			t.Errorf("got champion %q want %q", tournament.Champion, "Tiest")
+		}
+	})
+}
+
+func TestDoubleElimination(t *testing.T) {
+	t.Run("a first round loser can still win", func(t *testing.T) {
+		tournament, err := NewTournament("Monthly", DoubleElimination, []string{"A", "B", "C", "D"})
+		assertNoStoreError(t, err)
+
+		// winners: 1 A v D, 2 B v C, 3 final; losers: 4, 5; grand final 6
+		assertNoStoreError(t, tournament.RecordResult(1, "D"))
+		assertNoStoreError(t, tournament.RecordResult(2, "B"))
+		assertMatchPlayers(t, tournament.Matches[3], [2]string{"A", "C"})
+
+		assertNoStoreError(t, tournament.RecordResult(3, "D"))
+		assertNoStoreError(t, tournament.RecordResult(4, "A"))
+		assertMatchPlayers(t, tournament.Matches[4], [2]string{"A", "B"})
+
+		assertNoStoreError(t, tournament.RecordResult(5, "A"))
+		assertMatchPlayers(t, tournament.Matches[5], [2]string{"D", "A"})
+
+		// A has come through the losers bracket, so beating D once only
+		// forces a second final.
+		assertNoStoreError(t, tournament.RecordResult(6, "A"))
+		if tournament.Champion != "" || len(tournament.Matches) != 7 {
+			t.Fatalf("got champion %q after %d matches, want a bracket reset", tournament.Champion, len(tournament.Matches))
+		}
+		assertMatchPlayers(t, tournament.Matches[6], [2]string{"D", "A"})
+
+		assertNoStoreError(t, tournament.RecordResult(7, "A"))
+		if tournament.Champion != "A" {
+			t.Errorf("got champion %q want %q", tournament.Champion, "A")
+		}
+	})
+
+	t.Run("the winners bracket finalist wins without a reset", func(t *testing.T) {
+		tournament, err := NewTournament("Monthly", DoubleElimination, []string{"A", "B"})
+		assertNoStoreError(t, err)
+
+		// winners final 1, grand final 2
+		assertNoStoreError(t, tournament.RecordResult(1, "A"))
+		assertNoStoreError(t, tournament.RecordResult(2, "A"))
+
+		if tournament.Champion != "A" || len(tournament.Matches) != 2 {
+			t.Errorf("got champion %q after %d matches", tournament.Champion, len(tournament.Matches))
+		}
+	})
+
+	for n := 2; n <= 9; n++ {
+		for slot := 0; slot < 2; slot++ {
+			t.Run(fmt.Sprintf("%d players play to a champion, slot %d winning", n, slot), func(t *testing.T) {
+				players := make([]string, n)
+				for i := range players {
+					players[i] = fmt.Sprintf("P%d", i+1)
+				}
+				tournament, err := NewTournament("Monthly", DoubleElimination, players)
+				assertNoStoreError(t, err)
+
+				losses := playOut(t, tournament, slot)
+
+				if tournament.Champion == "" {
+					t.Fatal("expected a champion")
+				}
+				for _, player := range players {
+					lost := losses[player]
+					if player == tournament.Champion && lost > 1 || player != tournament.Champion && lost != 2 {
+						t.Errorf("%s lost %d times", player, lost)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	players := []string{"Cleo", "Chris", "Tiest", "Pepper", "Floyd"}
+	tournament, err := NewTournament("League", RoundRobin, players)
+	assertNoStoreError(t, err)
+
+	if len(tournament.Matches) != 10 {
+		t.Fatalf("got %d matches want 10", len(tournament.Matches))
+	}
+	pairs := make(map[string]bool)
+	for _, m := range tournament.Matches {
+		pair := m.Players[0] + "-" + m.Players[1]
+		if m.Players[0] > m.Players[1] {
+			pair = m.Players[1] + "-" + m.Players[0]
+		}
+		if pairs[pair] {
+			t.Errorf("%s play each other twice", pair)
+		}
+		pairs[pair] = true
+	}
+
+	for _, m := range tournament.Matches {
+		winner := m.Players[0]
+		if m.Players[1] == "Floyd" {
+			winner = "Floyd"
+		}
+		assertNoStoreError(t, tournament.RecordResult(m.ID, winner))
+	}
+	if tournament.Champion != "Floyd" || tournament.Standings[0].Wins != 4 {
+		t.Errorf("got champion %q with standings %v", tournament.Champion, tournament.Standings)
+	}
+}
+
+func TestTournamentsAPI(t *testing.T) {
+	store := NewInMemoryPlayerStore()
+	server := NewPlayerServer(store)
+
+	t.Run("creates a tournament", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCreateTournamentRequest(`{"name":"Monthly","format":"single-elimination","players":["Cleo","Chris"]}`))
+		assertResponseCode(t, response.Code, http.StatusCreated)
+		assertContentType(t, response, jsonContentType)
+
+		got := getTournamentFromResponse(t, response)
+		if got.Name != "Monthly" || len(got.Matches) != 1 {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("refuses a duplicate tournament", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCreateTournamentRequest(`{"name":"Monthly","format":"round-robin","players":["Cleo","Chris"]}`))
+		assertResponseCode(t, response.Code, http.StatusConflict)
+	})
+
+	t.Run("refuses an unknown format", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCreateTournamentRequest(`{"name":"Weekly","format":"swiss","players":["Cleo","Chris"]}`))
+		assertResponseCode(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("records match results into the player store", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newMatchResultRequest("Monthly", 1, "Chris"))
+		assertResponseCode(t, response.Code, http.StatusOK)
+
+		got := getTournamentFromResponse(t, response)
+		if got.Champion != "Chris" {
+			t.Errorf("got champion %q want %q", got.Champion, "Chris")
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Chris"), 1)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newMatchResultRequest("Monthly", 1, "Chris"))
+		assertResponseCode(t, response.Code, http.StatusConflict)
+		assertScoreEquals(t, store.GetPlayerScore("Chris"), 1)
+	})
+
+	t.Run("lists and shows tournaments", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/tournaments", nil)
+		server.ServeHTTP(response, request)
+		var names []string
+		json.NewDecoder(response.Body).Decode(&names)
+		if !reflect.DeepEqual(names, []string{"Monthly"}) {
+			t.Errorf("got %v want [Monthly]", names)
+		}
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodGet, "/tournaments/Weekly", nil)
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+}
+
+// playOut plays every match, letting the player in slot win, and counts
+// how often each player lost.
+func playOut(t *testing.T, tournament *Tournament, slot int) map[string]int {
+	t.Helper()
+	losses := make(map[string]int)
+	for progress := true; progress; {
+		progress = false
+		for _, m := range tournament.Matches {
+			if m.Done || !m.filled[0] || !m.filled[1] {
+				continue
+			}
+			assertNoStoreError(t, tournament.RecordResult(m.ID, m.Players[slot]))
+			losses[m.Players[1-slot]]++
+			progress = true
+		}
+	}
+	for _, m := range tournament.Matches {
+		if !m.Done {
+			t.Fatalf("match %+v was never played", m)
+		}
+	}
+	return losses
+}
+
+func newCreateTournamentRequest(body string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, "/tournaments", strings.NewReader(body))
+	return request
+}
+
+func newMatchResultRequest(name string, id int, winner string) *http.Request {
+	body := fmt.Sprintf(`{"winner":%q}`, winner)
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/tournaments/%s/matches/%d", name, id), strings.NewReader(body))
+	return request
+}
+
+func getTournamentFromResponse(t *testing.T, response *httptest.ResponseRecorder) Tournament {
+	t.Helper()
+	var got Tournament
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+		t.Fatalf("Unable to parse response %q into a Tournament, %v", response.Body, err)
+	}
+	return got
+}
+
+func assertMatchPlayers(t *testing.T, m *Match, want [2]string) {
+	t.Helper()
+	if m.Players != want {
+		t.Errorf("match %d has players %q want %q", m.ID, m.Players, want)
+	}
+}
+
+func assertTournamentError(t *testing.T, got, want error) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"embed"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	csrfCookie     = "csrf_token"
+	csrfField      = "csrf_token"
+	maxHistoryRows = 50
+)
+
+//go:embed templates static
+var uiFiles embed.FS
+
+var uiTemplates = map[string]*template.Template{
+	"league": parseUITemplate("templates/league.html"),
+	"player": parseUITemplate("templates/player.html"),
+}
+
+// HistoryStore remembers when a player's last maxHistoryRows wins were
+// recorded, oldest first.
+type HistoryStore interface {
+	WinHistory(name string) []time.Time
+}
+
+// appendHistory adds a win to a player's history, dropping the oldest
+// once it holds maxHistoryRows.
+func appendHistory(wins []time.Time, at time.Time) []time.Time {
+	if len(wins) >= maxHistoryRows {
+		wins = append(wins[:0], wins[len(wins)-maxHistoryRows+1:]...)
+	}
+	return append(wins, at)
+}
+
+// trimHistory keeps the latest maxHistoryRows wins.
+func trimHistory(wins []time.Time) []time.Time {
+	if len(wins) <= maxHistoryRows {
+		return wins
+	}
+	return append([]time.Time(nil), wins[len(wins)-maxHistoryRows:]...)
+}
+
+type uiPage struct {
+	CSRFToken string
+	Player    string
+	Wins      int
+	Profile   *Profile
+	History   []time.Time
+	League    []Player
+}
+
+func parseUITemplate(page string) *template.Template {
+	funcs := template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+		"playerURL": func(name string) string {
+			return "/ui/players/" + url.PathEscape(name)
+		},
+	}
+	return template.Must(template.New("layout").Funcs(funcs).ParseFS(uiFiles, "templates/layout.html", page))
+}
+
+func (p *PlayerServer) uiHandler() http.Handler {
+	static, _ := fs.Sub(uiFiles, "static")
+
+	router := http.NewServeMux()
+	router.Handle("/ui/static/", http.StripPrefix("/ui/static/", http.FileServer(http.FS(static))))
+	router.HandleFunc("/ui/players/", p.uiPlayer)
+	router.HandleFunc("/ui/wins", p.uiRecordWin)
+	router.HandleFunc("/ui/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ui/" {
+			http.NotFound(w, r)
+			return
+		}
+		p.uiLeague(w, r)
+	})
+	return router
+}
+
+func (p *PlayerServer) uiLeague(w http.ResponseWriter, r *http.Request) {
+	league := p.getLeagueTable()
+	sort.SliceStable(league, func(i, j int) bool {
+		if league[i].Wins != league[j].Wins {
+			return league[i].Wins > league[j].Wins
+		}
+		return league[i].Name < league[j].Name
+	})
+	renderUI(w, "league", uiPage{CSRFToken: csrfToken(w, r), League: league})
+}
+
+func (p *PlayerServer) uiPlayer(w http.ResponseWriter, r *http.Request) {
+	name, err := p.playerName(strings.TrimPrefix(r.URL.Path, "/ui/players/"))
+	if err != nil || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	page := uiPage{
+		CSRFToken: csrfToken(w, r),
+		Player:    name,
+		Wins:      p.store.GetPlayerScore(name),
+	}
+	if profiles, ok := p.store.(ProfileStore); ok {
+		if profile, err := profiles.GetProfile(name); err == nil {
+			page.Profile = &profile
+		}
+	}
+	if history, ok := p.store.(HistoryStore); ok {
+		wins := history.WinHistory(name)
+		for i := len(wins) - 1; i >= 0 && len(page.History) < maxHistoryRows; i-- {
+			page.History = append(page.History, wins[i])
+		}
+	}
+	if page.Wins == 0 && page.Profile == nil {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	renderUI(w, "player", page)
+}
+
+func (p *PlayerServer) uiRecordWin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !validCSRF(r) {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return
+	}
+	name, err := p.playerName(strings.TrimSpace(r.PostFormValue("name")))
+	if err != nil || name == "" || strings.Contains(name, "/") {
+		http.Error(w, "a player name is required", http.StatusBadRequest)
+		return
+	}
+
+	p.recordWin(name)
+	http.Redirect(w, r, "/ui/players/"+url.PathEscape(name), http.StatusSeeOther)
+}
+
+func renderUI(w http.ResponseWriter, name string, page uiPage) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	if err := uiTemplates[name].ExecuteTemplate(w, "layout", page); err != nil {
+		log.Printf("could not render %s page: %v", name, err)
+	}
+}
+
+// csrfToken returns the token from the visitor's cookie, issuing a new
+// one if they don't have it yet. Forms echo it back so a cross-site post,
+// which can't read the cookie, is rejected.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	token, err := randomHex(32)
+	if err != nil {
+		log.Printf("could not create CSRF token: %v", err)
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/ui/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	form := r.PostFormValue(csrfField)
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(form)) == 1
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI(t *testing.T) {
+	t.Run("renders the league table with a CSRF token", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Floyd")
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/ui/"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertContentType(t, response, "text/html; charset=utf-8")
+		body := response.Body.String()
+		if strings.Index(body, "Pepper") > strings.Index(body, "Floyd") {
+			t.Error("expected Pepper to be listed above Floyd")
+		}
+		token := csrfCookieFrom(t, response)
+		assertBodyContains(t, body, `name="csrf_token" value="`+token+`"`)
+	})
+
+	t.Run("renders a player's profile and history", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		store.SaveProfile("Pepper", Profile{DisplayName: "Pep <3", Team: "Red", JoinedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)})
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIRequest("/ui/players/Pepper"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		body := response.Body.String()
+		assertBodyContains(t, body, "Pep &lt;3")
+		assertBodyContains(t, body, "2 Jan 2020")
+		assertBodyContains(t, body, "2 wins")
+		if got := strings.Count(body, "<time "); got != 2 {
+			t.Errorf("got %d history rows want 2", got)
+		}
+	})
+
+	t.Run("returns 404 for unknown players", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/ui/players/Nobody"))
+
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("records a win from the form", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newUIWinRequest("Pepper", "tok", "tok"))
+
+		assertResponseCode(t, response.Code, http.StatusSeeOther)
+		if got := response.Header().Get("Location"); got != "/ui/players/Pepper" {
+			t.Errorf("got redirect to %q", got)
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("rejects a form without a matching CSRF token", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := NewPlayerServer(store)
+
+		for _, cookie := range []string{"", "other"} {
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, newUIWinRequest("Pepper", cookie, "tok"))
+			assertResponseCode(t, response.Code, http.StatusForbidden)
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 0)
+	})
+
+	t.Run("serves embedded static assets", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUIRequest("/ui/static/style.css"))
+
+		assertResponseCode(t, response.Code, http.StatusOK)
+		assertBodyContains(t, response.Body.String(), "border-collapse")
+	})
+}
+
+func newUIRequest(path string) *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, path, nil)
+	return request
+}
+
+func newUIWinRequest(name, cookie, token string) *http.Request {
+	form := url.Values{"name": {name}, csrfField: {token}}
+	request, _ := http.NewRequest(http.MethodPost, "/ui/wins", strings.NewReader(form.Encode()))
+	request.Header.Set("content-type", "application/x-www-form-urlencoded")
+	if cookie != "" {
+		request.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+	}
+	return request
+}
+
+func csrfCookieFrom(t *testing.T, response *httptest.ResponseRecorder) string {
+	t.Helper()
+	for _, c := range response.Result().Cookies() {
+		if c.Name == csrfCookie {
+			return c.Value
+		}
+	}
+	t.Fatal("expected a CSRF cookie to be set")
+	return ""
+}
+
+func assertBodyContains(t *testing.T, body, want string) {
+	t.Helper()
+	if !strings.Contains(body, want) {
+		t.Errorf("expected body to contain %q, got %q", want, body)
+	}
+}
+
+func TestWinHistory(t *testing.T) {
+	wal := newTestWALStore(t, t.TempDir(), WALOptions{})
+	defer wal.Close()
+	stores := map[string]PlayerStore{
+		"memory": NewInMemoryPlayerStore(),
+		"wal":    wal,
+		"events": newTestEventStore(t, NewInMemoryEventLog()),
+	}
+	for name, store := range stores {
+		t.Run(name+" keeps only the latest wins", func(t *testing.T) {
+			assertNoStoreError(t, RecordWins(store, []WinIncrement{{Name: "Pepper", Wins: maxHistoryRows + 10}}))
+			before := time.Now().UTC()
+			store.RecordWin("Pepper")
+
+			wins := store.(HistoryStore).WinHistory("Pepper")
+			if len(wins) != maxHistoryRows {
+				t.Fatalf("got %d wins in history want %d", len(wins), maxHistoryRows)
+			}
+			if wins[len(wins)-1].Before(before) {
+				t.Errorf("latest win %v is missing", wins[len(wins)-1])
+			}
+			assertScoreEquals(t, store.GetPlayerScore("Pepper"), maxHistoryRows+11)
+		})
+	}
+}
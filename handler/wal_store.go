@@ -0,0 +1,704 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	walLogFile      = "wal.log"
+	walSnapshotFile = "snapshot.json"
+	walHeaderSize   = 8
+	walMaxRecord    = 1 << 20
+
+	// defaultSnapshotEvery keeps replay after a restart short without
+	// rewriting the snapshot too often.
+	defaultSnapshotEvery = 1000
+)
+
+var ErrCorruptLog = errors.New("write-ahead log is corrupt before its final record")
+
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log after every record.
+	SyncAlways SyncPolicy = iota
+	// SyncBatch fsyncs once BatchSize records have been written.
+	SyncBatch
+	// SyncInterval fsyncs from a background goroutine every Interval.
+	SyncInterval
+)
+
+// WALOptions tunes a WALPlayerStore. SnapshotEvery is how many records
+// are logged between snapshots; zero means every 1000 and a negative
+// value turns periodic snapshots off.
+type WALOptions struct {
+	Sync          SyncPolicy
+	BatchSize     int
+	Interval      time.Duration
+	SnapshotEvery int
+}
+
+type walRecord struct {
+	Seq     uint64         `json:"seq"`
+	Op      string         `json:"op"`
+	Name    string         `json:"name"`
+	Time    time.Time      `json:"time"`
+	Profile *Profile       `json:"profile,omitempty"`
+	Team    *Team          `json:"team,omitempty"`
+	Into    string         `json:"into,omitempty"`
+	Wins    []WinIncrement `json:"wins,omitempty"`
+
+	Achievement *Achievement `json:"achievement,omitempty"`
+}
+
+type walSnapshot struct {
+	Seq      uint64                 `json:"seq"`
+	Scores   map[string]int         `json:"scores"`
+	Profiles map[string]Profile     `json:"profiles,omitempty"`
+	Teams    map[string]Team        `json:"teams,omitempty"`
+	History  map[string][]time.Time `json:"history,omitempty"`
+
+	Achievements map[string][]Achievement `json:"achievements,omitempty"`
+	Streaks      map[string]int           `json:"streaks,omitempty"`
+}
+
+// WALPlayerStore keeps scores in memory and appends every change to a
+// write-ahead log so they survive a restart.
+type WALPlayerStore struct {
+	enforcedNames
+
+	mu            sync.Mutex
+	dir           string
+	opts          WALOptions
+	log           *os.File
+	scores        map[string]int
+	profiles      map[string]Profile
+	teams         map[string]Team
+	history       map[string][]time.Time
+	achievements  map[string][]Achievement
+	streaks       map[string]int
+	index         *NameIndex
+	seq           uint64
+	unsynced      int
+	sinceSnapshot int
+	err           error
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func NewWALPlayerStore(dir string, opts WALOptions) (*WALPlayerStore, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.SnapshotEvery == 0 {
+		opts.SnapshotEvery = defaultSnapshotEvery
+	}
+
+	s := &WALPlayerStore{
+		dir:      dir,
+		opts:     opts,
+		scores:   make(map[string]int),
+		profiles: make(map[string]Profile),
+		teams:    make(map[string]Team),
+		history:  make(map[string][]time.Time),
+		index:    NewNameIndex(),
+		done:     make(chan struct{}),
+
+		achievements: make(map[string][]Achievement),
+		streaks:      make(map[string]int),
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, walLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.log = f
+
+	if opts.Sync == SyncInterval {
+		s.wg.Add(1)
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+func (s *WALPlayerStore) GetPlayerScore(name string) int {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scores[name]
+}
+
+func (s *WALPlayerStore) RecordWin(name string) {
+	normalized, err := s.normalize(name)
+	if err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	name = normalized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := walRecord{Op: "win", Name: name}
+	if err := s.append(&rec); err != nil {
+		log.Printf("could not record win for %q: %v", name, err)
+		return
+	}
+	s.apply(rec)
+	s.maybeSnapshot()
+}
+
+func (s *WALPlayerStore) GetLeague() []Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	players := make([]Player, 0, len(s.scores))
+	for k, v := range s.scores {
+		players = append(players, Player{Name: k, Wins: v})
+	}
+	return players
+}
+
+func (s *WALPlayerStore) GetProfile(name string) (Profile, error) {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile, ok := s.profiles[name]
+	if !ok {
+		return Profile{}, ErrProfileNotFound
+	}
+	return profile.clone(), nil
+}
+
+func (s *WALPlayerStore) SaveProfile(name string, profile Profile) error {
+	name, err := s.normalize(name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile = profile.clone()
+	if err := s.append(&walRecord{Op: "profile", Name: name, Profile: &profile}); err != nil {
+		return err
+	}
+	s.profiles[name] = profile
+	s.maybeSnapshot()
+	return nil
+}
+
+func (s *WALPlayerStore) DeleteProfile(name string) error {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	if err := s.append(&walRecord{Op: "delete-profile", Name: name}); err != nil {
+		return err
+	}
+	delete(s.profiles, name)
+	s.maybeSnapshot()
+	return nil
+}
+
+func (s *WALPlayerStore) GetTeam(name string) (Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team, ok := s.teams[name]
+	if !ok {
+		return Team{}, ErrTeamNotFound
+	}
+	return team.clone(), nil
+}
+
+func (s *WALPlayerStore) GetTeams() ([]Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	teams := make([]Team, 0, len(s.teams))
+	for _, team := range s.teams {
+		teams = append(teams, team.clone())
+	}
+	return teams, nil
+}
+
+func (s *WALPlayerStore) SaveTeam(team Team) error {
+	members, err := s.normalizeAll(team.Members)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team = team.clone()
+	team.Members = members
+	team.Wins = s.teams[team.Name].Wins
+	if err := s.append(&walRecord{Op: "team", Name: team.Name, Team: &team}); err != nil {
+		return err
+	}
+	s.teams[team.Name] = team
+	s.maybeSnapshot()
+	return nil
+}
+
+// RecordTeamWin logs a single record so a crash can't credit only some
+// of the members.
+func (s *WALPlayerStore) RecordTeamWin(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.teams[name]; !ok {
+		return ErrTeamNotFound
+	}
+	rec := walRecord{Op: "team-win", Name: name}
+	if err := s.append(&rec); err != nil {
+		return err
+	}
+	s.apply(rec)
+	s.maybeSnapshot()
+	return nil
+}
+
+func (s *WALPlayerStore) MergePlayer(from, into string) error {
+	into, err := s.normalize(into)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := walRecord{Op: "merge", Name: from, Into: into}
+	if err := s.append(&rec); err != nil {
+		return err
+	}
+	s.apply(rec)
+	s.maybeSnapshot()
+	return nil
+}
+
+func (s *WALPlayerStore) Achievements(name string) []Achievement {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Achievement(nil), s.achievements[name]...)
+}
+
+// Award logs the achievement unless the player already holds the badge,
+// and reports whether it was new.
+func (s *WALPlayerStore) Award(name string, a Achievement) bool {
+	name, err := s.normalize(name)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holdsBadge(s.achievements[name], a.Badge) {
+		return false
+	}
+	rec := walRecord{Op: "award", Name: name, Achievement: &a}
+	if err := s.append(&rec); err != nil {
+		log.Printf("could not award %q to %q: %v", a.Badge, name, err)
+		return false
+	}
+	s.apply(rec)
+	s.maybeSnapshot()
+	return true
+}
+
+func (s *WALPlayerStore) Streak(name string) int {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streaks[name]
+}
+
+func (s *WALPlayerStore) SearchPlayers(query string) []NameMatch {
+	return s.index.Search(query)
+}
+
+func (s *WALPlayerStore) WinHistory(name string) []time.Time {
+	name = s.lookup(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.history[name]...)
+}
+
+// Err returns the first error the store hit while writing to disk.
+func (s *WALPlayerStore) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Ping reports whether the log is still writable.
+func (s *WALPlayerStore) Ping(ctx context.Context) error {
+	return s.Err()
+}
+
+// Sync flushes every written record to stable storage.
+func (s *WALPlayerStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync()
+}
+
+// Snapshot writes the current scores to disk and starts a fresh log.
+func (s *WALPlayerStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// Close flushes and closes the log. Closing again returns the first
+// result.
+func (s *WALPlayerStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closeErr = s.sync()
+		if err := s.log.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
+}
+
+func (s *WALPlayerStore) append(rec *walRecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	rec.Seq = s.seq + 1
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	if _, err := s.log.Write(buf); err != nil {
+		s.err = err
+		return err
+	}
+	s.seq = rec.Seq
+	s.unsynced++
+	s.sinceSnapshot++
+
+	switch s.opts.Sync {
+	case SyncAlways:
+		return s.sync()
+	case SyncBatch:
+		if s.unsynced >= s.opts.BatchSize {
+			return s.sync()
+		}
+	}
+	return nil
+}
+
+func (s *WALPlayerStore) sync() error {
+	if s.unsynced == 0 {
+		return s.err
+	}
+	if err := s.log.Sync(); err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return err
+	}
+	s.unsynced = 0
+	return nil
+}
+
+func (s *WALPlayerStore) syncLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.sync(); err != nil {
+				log.Printf("could not sync write-ahead log: %v", err)
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *WALPlayerStore) maybeSnapshot() {
+	if s.opts.SnapshotEvery <= 0 || s.sinceSnapshot < s.opts.SnapshotEvery {
+		return
+	}
+	if err := s.snapshot(); err != nil {
+		log.Printf("could not snapshot player store: %v", err)
+	}
+}
+
+func (s *WALPlayerStore) snapshot() error {
+	if err := s.sync(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(walSnapshot{
+		Seq:          s.seq,
+		Scores:       s.scores,
+		Profiles:     s.profiles,
+		Teams:        s.teams,
+		History:      s.history,
+		Achievements: s.achievements,
+		Streaks:      s.streaks,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, walSnapshotFile), data); err != nil {
+		return err
+	}
+
+	// Records up to s.seq are now durably covered by the snapshot, so a
+	// crash before the truncate below only leaves records replay will
+	// skip.
+	if err := s.log.Truncate(0); err != nil {
+		s.err = err
+		return err
+	}
+	s.sinceSnapshot = 0
+	return s.log.Sync()
+}
+
+func (s *WALPlayerStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, walSnapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap walSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	s.seq = snap.Seq
+	if snap.Scores != nil {
+		s.scores = snap.Scores
+	}
+	for name := range s.scores {
+		s.index.Add(name)
+	}
+	if snap.Profiles != nil {
+		s.profiles = snap.Profiles
+	}
+	if snap.Teams != nil {
+		s.teams = snap.Teams
+	}
+	if snap.History != nil {
+		for name, wins := range snap.History {
+			snap.History[name] = trimHistory(wins)
+		}
+		s.history = snap.History
+	}
+	if snap.Achievements != nil {
+		s.achievements = snap.Achievements
+	}
+	if snap.Streaks != nil {
+		s.streaks = snap.Streaks
+	}
+	return nil
+}
+
+func (s *WALPlayerStore) replayLog() error {
+	path := filepath.Join(s.dir, walLogFile)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		rec, n, err := readWALRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Only the last record may be damaged: that is a write the
+			// process did not finish before it crashed.
+			if good+n < size && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrCorruptLog
+			}
+			log.Printf("discarding torn record at offset %d of %s", good, path)
+			return os.Truncate(path, good)
+		}
+		good += n
+
+		if rec.Seq <= s.seq {
+			continue
+		}
+		s.apply(rec)
+		s.seq = rec.Seq
+	}
+}
+
+func (s *WALPlayerStore) apply(rec walRecord) {
+	switch rec.Op {
+	case "win":
+		s.credit(rec.Name, 1, rec.Time)
+		advanceStreaks(s.streaks, rec.Name)
+	case "profile":
+		if rec.Profile != nil {
+			s.profiles[rec.Name] = *rec.Profile
+		}
+	case "delete-profile":
+		delete(s.profiles, rec.Name)
+	case "team":
+		if rec.Team != nil {
+			s.teams[rec.Name] = *rec.Team
+		}
+	case "team-win":
+		team, ok := s.teams[rec.Name]
+		if !ok {
+			return
+		}
+		team.Wins++
+		s.teams[rec.Name] = team
+		for _, m := range team.Members {
+			s.credit(m, 1, rec.Time)
+		}
+		advanceStreaks(s.streaks, team.Members...)
+	case "batch":
+		for _, inc := range rec.Wins {
+			s.credit(inc.Name, inc.Wins, rec.Time)
+		}
+		advanceStreaks(s.streaks, batchWinners(rec.Wins)...)
+	case "award":
+		if rec.Achievement != nil {
+			s.achievements[rec.Name] = append(s.achievements[rec.Name], *rec.Achievement)
+		}
+	case "merge":
+		mergePlayerState(rec.Name, rec.Into, s.scores, s.history, s.profiles, s.teams, s.achievements, s.streaks)
+		s.index.Remove(rec.Name)
+		if s.scores[rec.Into] > 0 {
+			s.index.Add(rec.Into)
+		}
+	}
+}
+
+func (s *WALPlayerStore) credit(name string, wins int, at time.Time) {
+	if s.scores[name] == 0 {
+		s.index.Add(name)
+	}
+	s.scores[name] += wins
+	for i := 0; i < wins; i++ {
+		s.history[name] = appendHistory(s.history[name], at)
+	}
+}
+
+func readWALRecord(r io.Reader) (walRecord, int64, error) {
+	var rec walRecord
+	header := make([]byte, walHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil {
+		return rec, int64(n), err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > walMaxRecord {
+		return rec, walHeaderSize, errors.New("record length out of range")
+	}
+	payload := make([]byte, length)
+	n, err = io.ReadFull(r, payload)
+	read := int64(walHeaderSize + n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return rec, read, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, read, errors.New("record checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, read, err
+	}
+	return rec, read, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a rename in dir durable; until the directory itself is
+// synced a crash can bring back the old file.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWALPlayerStore(t *testing.T) {
+	t.Run("replays wins after a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{Sync: SyncAlways})
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		store.RecordWin("Floyd")
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{Sync: SyncAlways})
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+		assertScoreEquals(t, store.GetPlayerScore("Floyd"), 1)
+	})
+
+	t.Run("replays the log on top of a snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{Sync: SyncBatch, BatchSize: 2, SnapshotEvery: 3})
+		for i := 0; i < 5; i++ {
+			store.RecordWin("Pepper")
+		}
+		assertNoStoreError(t, store.Close())
+
+		if _, err := os.Stat(filepath.Join(dir, walSnapshotFile)); err != nil {
+			t.Fatalf("expected a snapshot to be written, %v", err)
+		}
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 5)
+	})
+
+	t.Run("skips log records already in the snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		logBefore, _ := os.ReadFile(filepath.Join(dir, walLogFile))
+		assertNoStoreError(t, store.Snapshot())
+		assertNoStoreError(t, store.Close())
+
+		// simulate a crash between writing the snapshot and truncating the log
+		if err := os.WriteFile(filepath.Join(dir, walLogFile), logBefore, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("tolerates a torn final record", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		assertNoStoreError(t, store.Close())
+
+		path := filepath.Join(dir, walLogFile)
+		data, _ := os.ReadFile(path)
+		if err := os.WriteFile(path, data[:len(data)-5], 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+		store.RecordWin("Pepper")
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("refuses a log corrupted before its final record", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		assertNoStoreError(t, store.Close())
+
+		path := filepath.Join(dir, walLogFile)
+		data, _ := os.ReadFile(path)
+		data[walHeaderSize+2] ^= 0xff
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := NewWALPlayerStore(dir, WALOptions{})
+		if err != ErrCorruptLog {
+			t.Errorf("got error %v, want %v", err, ErrCorruptLog)
+		}
+	})
+
+	t.Run("syncs on an interval", func(t *testing.T) {
+		store := newTestWALStore(t, t.TempDir(), WALOptions{Sync: SyncInterval, Interval: time.Millisecond})
+		defer store.Close()
+		store.RecordWin("Pepper")
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			store.mu.Lock()
+			unsynced := store.unsynced
+			store.mu.Unlock()
+			if unsynced == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("log was not synced by the background loop")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("snapshots periodically by default", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{Sync: SyncBatch})
+		for i := 0; i < defaultSnapshotEvery; i++ {
+			store.RecordWin("Pepper")
+		}
+		assertNoStoreError(t, store.Close())
+
+		if _, err := os.Stat(filepath.Join(dir, walSnapshotFile)); err != nil {
+			t.Fatalf("expected a snapshot, %v", err)
+		}
+		if info, _ := os.Stat(filepath.Join(dir, walLogFile)); info.Size() != 0 {
+			t.Errorf("log still holds %d bytes after the snapshot", info.Size())
+		}
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), defaultSnapshotEvery)
+	})
+
+	t.Run("can be closed twice", func(t *testing.T) {
+		store := newTestWALStore(t, t.TempDir(), WALOptions{})
+		assertNoStoreError(t, store.Close())
+		assertNoStoreError(t, store.Close())
+	})
+
+	t.Run("persists profiles", func(t *testing.T) {
+		dir := t.TempDir()
+		profile := Profile{DisplayName: "Pep", Tags: []string{"lefty"}, JoinedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+		store := newTestWALStore(t, dir, WALOptions{})
+		assertNoStoreError(t, store.SaveProfile("Pepper", profile))
+		assertNoStoreError(t, store.SaveProfile("Floyd", Profile{DisplayName: "Floyd"}))
+		assertNoStoreError(t, store.Snapshot())
+		assertNoStoreError(t, store.DeleteProfile("Floyd"))
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		got, err := store.GetProfile("Pepper")
+		assertNoStoreError(t, err)
+		assertProfile(t, got, profile)
+		if _, err := store.GetProfile("Floyd"); err != ErrProfileNotFound {
+			t.Errorf("got error %v, want %v", err, ErrProfileNotFound)
+		}
+	})
+
+	t.Run("persists teams and team wins", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		assertNoStoreError(t, store.SaveTeam(Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}}))
+		assertNoStoreError(t, store.RecordTeamWin("Spicy"))
+		assertNoStoreError(t, store.Snapshot())
+		assertNoStoreError(t, store.RecordTeamWin("Spicy"))
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		team, err := store.GetTeam("Spicy")
+		assertNoStoreError(t, err)
+		assertTeam(t, team, Team{Name: "Spicy", Members: []string{"Pepper", "Chilli"}, Wins: 2})
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 2)
+		assertScoreEquals(t, store.GetPlayerScore("Chilli"), 2)
+	})
+
+	t.Run("persists badges and streaks", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newTestWALStore(t, dir, WALOptions{})
+		server := NewPlayerServer(store, WithAchievements(NewAchievementEngine()))
+		recordWins(server, "Floyd", "Pepper", "Pepper")
+		assertNoStoreError(t, store.Snapshot())
+		recordWins(server, "Pepper")
+		assertNoStoreError(t, store.Close())
+
+		store = newTestWALStore(t, dir, WALOptions{})
+		defer store.Close()
+		engine := NewAchievementEngine()
+		server = NewPlayerServer(store, WithAchievements(engine))
+		assertBadges(t, engine.Badges("Floyd"), []string{BadgeFirstWin})
+		for i := 0; i < 7; i++ {
+			recordWins(server, "Pepper")
+		}
+		assertBadges(t, engine.Badges("Pepper"), []string{BadgeFirstWin, BadgeBeatTheLeader, BadgeWinStreak})
+	})
+
+	t.Run("works behind the player server", func(t *testing.T) {
+		store := newTestWALStore(t, t.TempDir(), WALOptions{})
+		defer store.Close()
+		server := NewPlayerServer(store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Pepper"))
+		checkFoundWithBody(t, server, "Pepper", "1")
+	})
+}
+
+func newTestWALStore(t *testing.T, dir string, opts WALOptions) *WALPlayerStore {
+	t.Helper()
+	store, err := NewWALPlayerStore(dir, opts)
+	if err != nil {
+		t.Fatalf("could not open store, %v", err)
+	}
+	return store
+}
+
+func assertScoreEquals(t *testing.T, got, want int) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got score %d want %d", got, want)
+	}
+}
+
+func assertNoStoreError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
@@ -0,0 +1,450 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	EventWinRecorded WebhookEvent = "win.recorded"
+	EventNewLeader   WebhookEvent = "leader.changed"
+	EventMilestone   WebhookEvent = "milestone.reached"
+)
+
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrPrivateWebhookTarget = errors.New("webhook target is a private or loopback address")
+)
+
+type Subscription struct {
+	ID     string         `json:"id"`
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	Secret string         `json:"secret,omitempty"`
+}
+
+// Delivery is a payload that could not be delivered after every retry.
+type Delivery struct {
+	SubscriptionID string          `json:"subscriptionId"`
+	Event          WebhookEvent    `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"lastError"`
+}
+
+type webhookPayload struct {
+	Event WebhookEvent `json:"event"`
+	Time  time.Time    `json:"time"`
+	Data  interface{}  `json:"data"`
+}
+
+type WinRecordedData struct {
+	Player string `json:"player"`
+	Wins   int    `json:"wins"`
+}
+
+type NewLeaderData struct {
+	Player   string `json:"player"`
+	Wins     int    `json:"wins"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// WebhookDispatcher signs and delivers league events to subscribers,
+// retrying with exponential backoff before giving up on a delivery.
+//
+// Subscriptions are managed over HTTP only by callers presenting
+// AdminToken as a bearer token; with no token set, /webhooks refuses
+// everyone. Unless AllowPrivateTargets is set, the default Client won't
+// connect to loopback, private or link-local addresses, so subscribers
+// can't point the server at itself or its network.
+type WebhookDispatcher struct {
+	Client              *http.Client
+	MaxAttempts         int
+	BaseDelay           time.Duration
+	Milestones          []int
+	AdminToken          string
+	AllowPrivateTargets bool
+
+	mu     sync.Mutex
+	subs   map[string]Subscription
+	dead   []Delivery
+	nextID int
+	wg     sync.WaitGroup
+}
+
+func NewWebhookDispatcher() *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		Milestones:  []int{10, 50, 100, 500, 1000},
+		subs:        make(map[string]Subscription),
+	}
+	// The address is checked as it is dialled, after DNS and on every
+	// redirect, so a public name can't lead somewhere private.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 5 * time.Second, Control: d.checkDial}).DialContext
+	d.Client = &http.Client{Timeout: 5 * time.Second, Transport: transport}
+	return d
+}
+
+func (d *WebhookDispatcher) checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !d.AllowPrivateTargets && isPrivateAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateWebhookTarget, address)
+	}
+	return nil
+}
+
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified() || addr.IsMulticast()
+}
+
+func (d *WebhookDispatcher) Subscribe(sub Subscription) (Subscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return sub, errors.New("url must be an absolute http or https URL")
+	}
+	if !d.AllowPrivateTargets {
+		host := u.Hostname()
+		if addr, err := netip.ParseAddr(host); (err == nil && isPrivateAddr(addr)) || strings.EqualFold(host, "localhost") {
+			return sub, ErrPrivateWebhookTarget
+		}
+	}
+	if len(sub.Events) == 0 {
+		return sub, errors.New("subscribe to at least one event")
+	}
+	for _, e := range sub.Events {
+		switch e {
+		case EventWinRecorded, EventNewLeader, EventMilestone:
+		default:
+			return sub, fmt.Errorf("unknown event %q", e)
+		}
+	}
+	if sub.Secret == "" {
+		sub.Secret, err = randomHex(32)
+		if err != nil {
+			return sub, err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	sub.ID = fmt.Sprintf("wh_%d", d.nextID)
+	d.subs[sub.ID] = sub
+	return sub, nil
+}
+
+func (d *WebhookDispatcher) Unsubscribe(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.subs[id]; !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(d.subs, id)
+	return nil
+}
+
+// Subscriptions lists subscribers without their secrets.
+func (d *WebhookDispatcher) Subscriptions() []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subs := make([]Subscription, 0, len(d.subs))
+	for _, s := range d.subs {
+		s.Secret = ""
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+func (d *WebhookDispatcher) DeadLetters() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Delivery(nil), d.dead...)
+}
+
+// Publish sends the event to every subscriber in the background.
+func (d *WebhookDispatcher) Publish(event WebhookEvent, data interface{}) {
+	body, err := json.Marshal(webhookPayload{Event: event, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sub := range d.subs {
+		if !sub.wants(event) {
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(sub, event, body)
+	}
+}
+
+// Wait blocks until every delivery in flight has succeeded or been moved
+// to the dead-letter list.
+func (d *WebhookDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *WebhookDispatcher) deliver(sub Subscription, event WebhookEvent, body []byte) {
+	defer d.wg.Done()
+
+	var lastErr error
+	delay := d.BaseDelay
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		if lastErr = d.post(sub, event, body); lastErr == nil {
+			return
+		}
+		if attempt < d.MaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dead = append(d.dead, Delivery{
+		SubscriptionID: sub.ID,
+		Event:          event,
+		Payload:        body,
+		Attempts:       d.MaxAttempts,
+		LastError:      lastErr.Error(),
+	})
+}
+
+func (d *WebhookDispatcher) post(sub Subscription, event WebhookEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("X-Webhook-Event", string(event))
+	req.Header.Set(webhookSignatureHeader, SignWebhookPayload(sub.Secret, body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("receiver returned status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// milestonesCrossed returns the milestones in (before, after], so a
+// batch that jumps past several of them still announces each one.
+func (d *WebhookDispatcher) milestonesCrossed(before, after int) []int {
+	var crossed []int
+	for _, m := range d.Milestones {
+		if m > before && m <= after {
+			crossed = append(crossed, m)
+		}
+	}
+	sort.Ints(crossed)
+	return crossed
+}
+
+func (s Subscription) wants(event WebhookEvent) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload returns the value receivers should find in the
+// X-Webhook-Signature header.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// podium follows the two best scores as wins are recorded, so finding
+// the leader or a winner's rival doesn't mean reading the whole league.
+// Scores only go up, so the two best are always among the players seen.
+type podium struct {
+	mu  sync.Mutex
+	top [2]Player
+}
+
+func newPodium(league []Player) *podium {
+	p := &podium{}
+	for _, player := range league {
+		p.observe(player.Name, player.Wins)
+	}
+	return p
+}
+
+// observe records a player's new score.
+func (p *podium) observe(name string, wins int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.top[0].Name == name:
+		p.top[0].Wins = max(p.top[0].Wins, wins)
+	case p.top[1].Name == name:
+		p.top[1].Wins = max(p.top[1].Wins, wins)
+	case wins > p.top[1].Wins:
+		p.top[1] = Player{Name: name, Wins: wins}
+	}
+	if p.top[1].Wins > p.top[0].Wins {
+		p.top[0], p.top[1] = p.top[1], p.top[0]
+	}
+}
+
+// leader returns the player with the most wins, or an empty Player when
+// nobody has won yet.
+func (p *podium) leader() Player {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.top[0]
+}
+
+// rival returns the best score of anyone but name.
+func (p *podium) rival(name string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.top[0].Name == name {
+		return p.top[1].Wins
+	}
+	return p.top[0].Wins
+}
+
+// updatePodium reads the named players' scores after a win.
+func (p *PlayerServer) updatePodium(names ...string) {
+	if p.podium == nil {
+		return
+	}
+	for _, name := range names {
+		p.podium.observe(name, p.store.GetPlayerScore(name))
+	}
+}
+
+// scoresBefore reads the named players' scores ahead of a win, for the
+// achievement rules and for publishWins to tell which milestones the win
+// crossed.
+func (p *PlayerServer) scoresBefore(names ...string) map[string]int {
+	if p.webhooks == nil && p.achievements == nil {
+		return nil
+	}
+	scores := make(map[string]int, len(names))
+	for _, name := range names {
+		scores[name] = p.store.GetPlayerScore(name)
+	}
+	return scores
+}
+
+// publishWins announces each named player's win once, however many
+// times they appear, along with every milestone since before.
+func (p *PlayerServer) publishWins(before map[string]int, names ...string) {
+	if p.webhooks == nil {
+		return
+	}
+	published := make(map[string]bool, len(names))
+	for _, name := range names {
+		if published[name] {
+			continue
+		}
+		published[name] = true
+		wins := p.store.GetPlayerScore(name)
+		p.webhooks.Publish(EventWinRecorded, WinRecordedData{Player: name, Wins: wins})
+		for _, m := range p.webhooks.milestonesCrossed(before[name], wins) {
+			p.webhooks.Publish(EventMilestone, WinRecordedData{Player: name, Wins: m})
+		}
+	}
+
+	p.leaderMu.Lock()
+	defer p.leaderMu.Unlock()
+	top := p.podium.leader()
+	// Drawing level with the current leader doesn't take first place.
+	if top.Name == "" || top.Name == p.leaderName || top.Wins <= p.store.GetPlayerScore(p.leaderName) {
+		return
+	}
+	p.webhooks.Publish(EventNewLeader, NewLeaderData{Player: top.Name, Wins: top.Wins, Previous: p.leaderName})
+	p.leaderName = top.Name
+}
+
+func (p *PlayerServer) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if p.webhooks == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !p.webhooks.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "webhooks are managed with the admin token", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/webhooks"), "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, p.webhooks.Subscriptions())
+	case path == "" && r.Method == http.MethodPost:
+		var sub Subscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			http.Error(w, "invalid subscription: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub, err := p.webhooks.Subscribe(sub)
+		if err != nil {
+			http.Error(w, "invalid subscription: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, sub)
+	case path == "dead-letters" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, p.webhooks.DeadLetters())
+	case path != "" && r.Method == http.MethodDelete:
+		if err := p.webhooks.Unsubscribe(path); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// authorized reports whether the request carries the admin token.
+func (d *WebhookDispatcher) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && d.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.AdminToken)) == 1
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int
+	received []webhookPayload
+	raw      []json.RawMessage
+	badSigs  int
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get(webhookSignatureHeader) != SignWebhookPayload(rc.secret, body) {
+		rc.badSigs++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var payload webhookPayload
+	json.Unmarshal(body, &payload)
+	rc.received = append(rc.received, payload)
+	rc.raw = append(rc.raw, body)
+}
+
+func (rc *webhookReceiver) events() []WebhookEvent {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	events := make([]WebhookEvent, len(rc.received))
+	for i, p := range rc.received {
+		events[i] = p.Event
+	}
+	return events
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Run("delivers signed win events", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventWinRecorded}, Secret: "s3cret"})
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(dispatcher))
+
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Pepper"))
+		dispatcher.Wait()
+
+		assertEvents(t, receiver.events(), []WebhookEvent{EventWinRecorded})
+		data := receiver.received[0].Data.(map[string]interface{})
+		if data["player"] != "Pepper" || data["wins"] != float64(1) {
+			t.Errorf("got data %v", data)
+		}
+	})
+
+	t.Run("announces a new leader only when first place changes hands", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventNewLeader}, Secret: "s3cret"})
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(dispatcher))
+
+		for _, name := range []string{"Pepper", "Floyd", "Floyd", "Floyd"} {
+			server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(name))
+			dispatcher.Wait()
+		}
+
+		assertEvents(t, receiver.events(), []WebhookEvent{EventNewLeader, EventNewLeader})
+		var got NewLeaderData
+		data, _ := json.Marshal(receiver.received[1].Data)
+		json.Unmarshal(data, &got)
+		if got != (NewLeaderData{Player: "Floyd", Wins: 2, Previous: "Pepper"}) {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("announces milestones", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		dispatcher.Milestones = []int{2}
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventMilestone}, Secret: "s3cret"})
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(dispatcher))
+
+		for i := 0; i < 3; i++ {
+			server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Pepper"))
+		}
+		dispatcher.Wait()
+
+		assertEvents(t, receiver.events(), []WebhookEvent{EventMilestone})
+	})
+
+	t.Run("announces every milestone a batch jumps past, once per player", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		dispatcher.Milestones = []int{3, 2, 10}
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventWinRecorded, EventMilestone}, Secret: "s3cret"})
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(dispatcher))
+
+		server.ServeHTTP(httptest.NewRecorder(), newBatchRequest(`[{"name": "Pepper", "wins": 2}, {"name": "Pepper", "wins": 2}]`))
+		dispatcher.Wait()
+
+		// Deliveries run concurrently, so their order isn't fixed.
+		got := make(map[WebhookEvent][]int)
+		receiver.mu.Lock()
+		for _, payload := range receiver.received {
+			var data WinRecordedData
+			raw, _ := json.Marshal(payload.Data)
+			json.Unmarshal(raw, &data)
+			got[payload.Event] = append(got[payload.Event], data.Wins)
+		}
+		receiver.mu.Unlock()
+		sort.Ints(got[EventMilestone])
+		want := map[WebhookEvent][]int{EventWinRecorded: {4}, EventMilestone: {2, 3}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("retries failed deliveries", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret", failures: 2}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventWinRecorded}, Secret: "s3cret"})
+
+		dispatcher.Publish(EventWinRecorded, WinRecordedData{Player: "Pepper", Wins: 1})
+		dispatcher.Wait()
+
+		assertEvents(t, receiver.events(), []WebhookEvent{EventWinRecorded})
+		if len(dispatcher.DeadLetters()) != 0 {
+			t.Errorf("expected no dead letters, got %v", dispatcher.DeadLetters())
+		}
+	})
+
+	t.Run("moves undeliverable payloads to the dead-letter list", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "wrong"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := newTestDispatcher()
+		sub, _ := dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventWinRecorded}, Secret: "s3cret"})
+
+		dispatcher.Publish(EventWinRecorded, WinRecordedData{Player: "Pepper", Wins: 1})
+		dispatcher.Wait()
+
+		dead := dispatcher.DeadLetters()
+		if len(dead) != 1 || dead[0].SubscriptionID != sub.ID || dead[0].Attempts != dispatcher.MaxAttempts {
+			t.Fatalf("got dead letters %+v", dead)
+		}
+		if receiver.badSigs != dispatcher.MaxAttempts {
+			t.Errorf("got %d attempts want %d", receiver.badSigs, dispatcher.MaxAttempts)
+		}
+	})
+
+	t.Run("refuses private targets by default", func(t *testing.T) {
+		dispatcher := NewWebhookDispatcher()
+		for _, target := range []string{"http://127.0.0.1:5000/", "http://[::1]/", "http://10.0.0.8/", "http://169.254.169.254/latest", "http://LOCALHOST/"} {
+			if _, err := dispatcher.Subscribe(Subscription{URL: target, Events: []WebhookEvent{EventWinRecorded}}); !errors.Is(err, ErrPrivateWebhookTarget) {
+				t.Errorf("got %v for %s, want ErrPrivateWebhookTarget", err, target)
+			}
+		}
+	})
+
+	t.Run("refuses to connect to private addresses by default", func(t *testing.T) {
+		receiver := &webhookReceiver{secret: "s3cret"}
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+
+		dispatcher := NewWebhookDispatcher()
+		dispatcher.BaseDelay = time.Millisecond
+		dispatcher.AllowPrivateTargets = true
+		dispatcher.Subscribe(Subscription{URL: target.URL, Events: []WebhookEvent{EventWinRecorded}, Secret: "s3cret"})
+		dispatcher.AllowPrivateTargets = false
+
+		dispatcher.Publish(EventWinRecorded, WinRecordedData{Player: "Pepper", Wins: 1})
+		dispatcher.Wait()
+
+		assertEvents(t, receiver.events(), nil)
+		if dead := dispatcher.DeadLetters(); len(dead) != 1 || !strings.Contains(dead[0].LastError, ErrPrivateWebhookTarget.Error()) {
+			t.Errorf("got dead letters %+v", dead)
+		}
+	})
+
+	t.Run("needs the admin token to manage subscriptions", func(t *testing.T) {
+		dispatcher := newTestDispatcher()
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(dispatcher))
+
+		for _, token := range []string{"", "wrong"} {
+			response := httptest.NewRecorder()
+			request, _ := http.NewRequest(http.MethodGet, "/webhooks/dead-letters", nil)
+			if token != "" {
+				request.Header.Set("Authorization", "Bearer "+token)
+			}
+			server.ServeHTTP(response, request)
+			assertResponseCode(t, response.Code, http.StatusUnauthorized)
+		}
+
+		dispatcher.AdminToken = ""
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newWebhooksRequest(http.MethodGet, "/webhooks", ""))
+		assertResponseCode(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("manages subscriptions over HTTP", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore(), WithWebhooks(newTestDispatcher()))
+
+		response := httptest.NewRecorder()
+		request := newWebhooksRequest(http.MethodPost, "/webhooks", `{"url":"http://bot.example/hook","events":["leader.changed"]}`)
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusCreated)
+		var created Subscription
+		json.NewDecoder(response.Body).Decode(&created)
+		if created.ID == "" || created.Secret == "" {
+			t.Fatalf("expected an id and generated secret, got %+v", created)
+		}
+
+		response = httptest.NewRecorder()
+		request = newWebhooksRequest(http.MethodPost, "/webhooks", `{"url":"http://bot.example/hook","events":["lost"]}`)
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusBadRequest)
+
+		response = httptest.NewRecorder()
+		request = newWebhooksRequest(http.MethodGet, "/webhooks", "")
+		server.ServeHTTP(response, request)
+		var listed []Subscription
+		json.NewDecoder(response.Body).Decode(&listed)
+		if len(listed) != 1 || listed[0].Secret != "" {
+			t.Errorf("got %+v, want one subscription without its secret", listed)
+		}
+
+		response = httptest.NewRecorder()
+		request = newWebhooksRequest(http.MethodDelete, "/webhooks/"+created.ID, "")
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusNoContent)
+
+		response = httptest.NewRecorder()
+		request = newWebhooksRequest(http.MethodDelete, "/webhooks/"+created.ID, "")
+		server.ServeHTTP(response, request)
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("webhooks are not served unless configured", func(t *testing.T) {
+		server := NewPlayerServer(NewInMemoryPlayerStore())
+		response := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/webhooks", nil)
+
+		server.ServeHTTP(response, request)
+
+		assertResponseCode(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func newTestDispatcher() *WebhookDispatcher {
+	dispatcher := NewWebhookDispatcher()
+	dispatcher.BaseDelay = time.Millisecond
+	dispatcher.MaxAttempts = 3
+	dispatcher.AdminToken = "admin-token"
+	dispatcher.AllowPrivateTargets = true
+	return dispatcher
+}
+
+func newWebhooksRequest(method, path, body string) *http.Request {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer admin-token")
+	return request
+}
+
+func assertEvents(t *testing.T, got, want []WebhookEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got events %v want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got events %v want %v", got, want)
+		}
+	}
+}
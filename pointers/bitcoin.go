@@ -0,0 +1,147 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount = errors.New("amount must be positive")
+	ErrInvalidFormat = errors.New("invalid amount")
+	ErrOverflow      = errors.New("amount out of range")
+)
+
+// Bitcoin is an amount in satoshis, the smallest unit, so fractions of a
+// coin are exact.
+type Bitcoin int64
+
+const (
+	Satoshi Bitcoin = 1
+	BTC     Bitcoin = 100_000_000
+
+	satoshiDigits = 8
+)
+
+// ParseBitcoin reads a decimal amount of coins such as "0.00012 BTC".
+// The unit is optional and at most eight decimal places are allowed.
+func ParseBitcoin(s string) (Bitcoin, error) {
+	text := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), string(CurrencyBTC)))
+	amount, err := parseMinorUnits(text, satoshiDigits)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", err, s)
+	}
+	return Bitcoin(amount), nil
+}
+
+// parseMinorUnits reads a signed decimal with up to decimals places as a
+// count of the smallest unit.
+func parseMinorUnits(text string, decimals int) (int64, error) {
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	} else {
+		text = strings.TrimPrefix(text, "+")
+	}
+	whole, frac, dot := strings.Cut(text, ".")
+	if whole == "" && frac == "" || dot && frac == "" || len(frac) > decimals || !digitsOnly(whole) || !digitsOnly(frac) {
+		return 0, ErrInvalidFormat
+	}
+
+	// Work in uint64 so the most negative amount, one unit bigger than
+	// the most positive, still parses.
+	limit := uint64(math.MaxInt64)
+	if negative {
+		limit++
+	}
+	unit := uint64(1)
+	for i := 0; i < decimals; i++ {
+		unit *= 10
+	}
+	coins := uint64(0)
+	if whole != "" {
+		var err error
+		if coins, err = strconv.ParseUint(whole, 10, 64); err != nil {
+			return 0, ErrOverflow
+		}
+	}
+	minor := uint64(0)
+	if frac != "" {
+		minor, _ = strconv.ParseUint(frac+strings.Repeat("0", decimals-len(frac)), 10, 64)
+	}
+	if coins > (limit-minor)/unit {
+		return 0, ErrOverflow
+	}
+	amount := coins*unit + minor
+	if negative {
+		return int64(-amount), nil
+	}
+	return int64(amount), nil
+}
+
+func digitsOnly(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount in coins without trailing zeros, so one
+// satoshi is "0.00000001 BTC" and two coins are "2 BTC".
+func (b Bitcoin) String() string {
+	return formatMinorUnits(int64(b), satoshiDigits) + " " + string(CurrencyBTC)
+}
+
+// Money converts the amount to the currency-neutral form.
+func (b Bitcoin) Money() Money {
+	return Money{Amount: int64(b), Currency: CurrencyBTC}
+}
+
+func formatMinorUnits(amount int64, decimals int) string {
+	sign := ""
+	u := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		u = uint64(-(amount + 1)) + 1
+	}
+	unit := uint64(1)
+	for i := 0; i < decimals; i++ {
+		unit *= 10
+	}
+	whole, frac := u/unit, u%unit
+	if frac == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%0*d", decimals, frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, digits)
+}
+
+// Add returns b+other, or ErrOverflow if the sum doesn't fit.
+func (b Bitcoin) Add(other Bitcoin) (Bitcoin, error) {
+	sum, err := addInt64(int64(b), int64(other))
+	return Bitcoin(sum), err
+}
+
+// Sub returns b-other, or ErrOverflow if the difference doesn't fit.
+func (b Bitcoin) Sub(other Bitcoin) (Bitcoin, error) {
+	diff, err := subInt64(int64(b), int64(other))
+	return Bitcoin(diff), err
+}
+
+func addInt64(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+func subInt64(a, b int64) (int64, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrOverflow
+	}
+	return a - b, nil
+}
@@ -0,0 +1,92 @@
+package pointers
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseBitcoin(t *testing.T) {
+	cases := map[string]Bitcoin{
+		"0.00012 BTC":   12_000 * Satoshi,
+		"1 BTC":         BTC,
+		"1.5":           BTC + BTC/2,
+		"0.00000001":    Satoshi,
+		".25 BTC":       BTC / 4,
+		"-2.1BTC":       -(2*BTC + BTC/10),
+		" 21000000 BTC": 21_000_000 * BTC,
+	}
+	for in, want := range cases {
+		t.Run(in, func(t *testing.T) {
+			got, err := ParseBitcoin(in)
+			assertNoError(t, err)
+			if got != want {
+				t.Errorf("got %d satoshis want %d", got, want)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "BTC", "1.", "1.000000001", "one", "1,5", "--1", "-+1", "1e3"} {
+		t.Run("rejects "+in, func(t *testing.T) {
+			if _, err := ParseBitcoin(in); err == nil {
+				t.Errorf("expected %q to be rejected", in)
+			}
+		})
+	}
+
+	t.Run("rejects amounts that overflow", func(t *testing.T) {
+		if _, err := ParseBitcoin("100000000000 BTC"); !errors.Is(err, ErrOverflow) {
+			t.Errorf("got %v want ErrOverflow", err)
+		}
+	})
+}
+
+func TestBitcoinString(t *testing.T) {
+	cases := map[Bitcoin]string{
+		0:                "0 BTC",
+		Satoshi:          "0.00000001 BTC",
+		12_000 * Satoshi: "0.00012 BTC",
+		2 * BTC:          "2 BTC",
+		-(BTC + BTC/2):   "-1.5 BTC",
+		math.MinInt64:    "-92233720368.54775808 BTC",
+	}
+	for amount, want := range cases {
+		if got := amount.String(); got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+
+	t.Run("parses what it prints", func(t *testing.T) {
+		for amount := range cases {
+			got, err := ParseBitcoin(amount.String())
+			assertNoError(t, err)
+			if got != amount {
+				t.Errorf("round trip of %d gave %d", amount, got)
+			}
+		}
+	})
+}
+
+func TestBitcoinArithmetic(t *testing.T) {
+	t.Run("adds and subtracts", func(t *testing.T) {
+		sum, err := BTC.Add(Satoshi)
+		assertNoError(t, err)
+		diff, err := sum.Sub(BTC)
+		assertNoError(t, err)
+		if sum != 100_000_001 || diff != Satoshi {
+			t.Errorf("got sum %d and difference %d", sum, diff)
+		}
+	})
+
+	overflows := []func() (Bitcoin, error){
+		func() (Bitcoin, error) { return Bitcoin(math.MaxInt64).Add(Satoshi) },
+		func() (Bitcoin, error) { return Bitcoin(math.MinInt64).Add(-Satoshi) },
+		func() (Bitcoin, error) { return Bitcoin(math.MinInt64).Sub(Satoshi) },
+		func() (Bitcoin, error) { return Bitcoin(math.MaxInt64).Sub(-Satoshi) },
+	}
+	for _, op := range overflows {
+		if _, err := op(); !errors.Is(err, ErrOverflow) {
+			t.Errorf("got %v want ErrOverflow", err)
+		}
+	}
+}
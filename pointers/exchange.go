@@ -0,0 +1,111 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// Exchanger knows how many units of one currency a unit of another is
+// worth.
+type Exchanger interface {
+	Rate(from, to Currency) (*big.Rat, error)
+}
+
+type currencyPair struct{ from, to Currency }
+
+// StaticExchanger serves rates it has been told, and works out the
+// reverse of any pair it knows. The zero value is ready to use.
+type StaticExchanger struct {
+	mu    sync.RWMutex
+	rates map[currencyPair]*big.Rat
+}
+
+// SetRate records that one from is worth rate of to, where rate is a
+// decimal or fraction such as "65000.50" or "1/3".
+func (e *StaticExchanger) SetRate(from, to Currency, rate string) error {
+	for _, c := range []Currency{from, to} {
+		if _, err := c.Decimals(); err != nil {
+			return err
+		}
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return fmt.Errorf("%w: rate %q", ErrInvalidFormat, rate)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.rates == nil {
+		e.rates = make(map[currencyPair]*big.Rat)
+	}
+	e.rates[currencyPair{from, to}] = r
+	return nil
+}
+
+func (e *StaticExchanger) Rate(from, to Currency) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if r, ok := e.rates[currencyPair{from, to}]; ok {
+		return new(big.Rat).Set(r), nil
+	}
+	if r, ok := e.rates[currencyPair{to, from}]; ok {
+		return new(big.Rat).Inv(r), nil
+	}
+	return nil, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+}
+
+// Convert exchanges m into currency to. Anything smaller than the target
+// currency's smallest unit is dropped, so converting never creates money.
+func Convert(m Money, to Currency, ex Exchanger) (Money, error) {
+	from, err := m.Currency.Decimals()
+	if err != nil {
+		return Money{}, err
+	}
+	into, err := to.Decimals()
+	if err != nil {
+		return Money{}, err
+	}
+	rate, err := ex.Rate(m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	// amount * rate * 10^into / 10^from, all exact until the final
+	// truncation.
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(into), pow10(from)))
+	amount := new(big.Int).Quo(v.Num(), v.Denom())
+	if !amount.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: amount.Int64(), Currency: to}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// TransferConverted takes amount out of from and pays it into to as
+// currency target, at ex's rate. It returns what to received.
+func TransferConverted(from, to *Wallet, amount Money, target Currency, ex Exchanger) (Money, error) {
+	if err := checkAmount(amount); err != nil {
+		return Money{}, err
+	}
+	in, err := Convert(amount, target, ex)
+	if err != nil {
+		return Money{}, err
+	}
+	if in.Amount <= 0 {
+		return Money{}, fmt.Errorf("%w: %s is worth nothing in %s", ErrInvalidAmount, amount, target)
+	}
+	if err := transfer(from, to, amount, in); err != nil {
+		return Money{}, err
+	}
+	return in, nil
+}
@@ -0,0 +1,99 @@
+package pointers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	rates := &StaticExchanger{}
+	assertNoError(t, rates.SetRate(CurrencyBTC, CurrencyUSD, "60000"))
+	assertNoError(t, rates.SetRate(CurrencyEUR, CurrencyUSD, "1.1"))
+
+	cases := []struct {
+		from, want string
+	}{
+		{"0.5 BTC", "30000 USD"},
+		{"0.001 BTC", "60 USD"},
+		{"30 USD", "0.0005 BTC"},
+		{"11 USD", "10 EUR"},
+		{"1 USD", "0.9 EUR"},
+		{"7 ETH", "7 ETH"},
+	}
+	for _, c := range cases {
+		t.Run(c.from, func(t *testing.T) {
+			from, _ := ParseMoney(c.from)
+			want, _ := ParseMoney(c.want)
+
+			got, err := Convert(from, want.Currency, rates)
+
+			assertNoError(t, err)
+			if got != want {
+				t.Errorf("got %s want %s", got, want)
+			}
+		})
+	}
+
+	t.Run("drops what's smaller than a cent", func(t *testing.T) {
+		got, err := Convert(Money{Amount: 1, Currency: CurrencyBTC}, CurrencyUSD, rates)
+		assertNoError(t, err)
+		if got.Amount != 0 {
+			t.Errorf("got %s want nothing", got)
+		}
+	})
+
+	t.Run("needs a rate", func(t *testing.T) {
+		_, err := Convert(Money{Amount: 1, Currency: CurrencyETH}, CurrencyUSD, rates)
+		if !errors.Is(err, ErrNoRate) {
+			t.Errorf("got %v want ErrNoRate", err)
+		}
+	})
+
+	t.Run("rejects rates that aren't positive", func(t *testing.T) {
+		for _, rate := range []string{"0", "-1", "lots"} {
+			if err := rates.SetRate(CurrencyETH, CurrencyUSD, rate); err == nil {
+				t.Errorf("expected rate %q to be rejected", rate)
+			}
+		}
+	})
+}
+
+func TestTransferConverted(t *testing.T) {
+	rates := &StaticExchanger{}
+	rates.SetRate(CurrencyBTC, CurrencyUSD, "60000")
+
+	t.Run("pays out in the target currency", func(t *testing.T) {
+		from, to := NewWallet(BTC), &Wallet{}
+
+		got, err := TransferConverted(from, to, (BTC / 10).Money(), CurrencyUSD, rates)
+
+		assertNoError(t, err)
+		want := Money{Amount: 600_000, Currency: CurrencyUSD}
+		if got != want || to.BalanceOf(CurrencyUSD) != want {
+			t.Errorf("got %s, balance %s, want %s", got, to.BalanceOf(CurrencyUSD), want)
+		}
+		assertBalance(t, from, BTC-BTC/10)
+	})
+
+	t.Run("leaves both wallets alone without a rate", func(t *testing.T) {
+		from, to := NewWallet(BTC), &Wallet{}
+
+		_, err := TransferConverted(from, to, BTC.Money(), CurrencyEUR, rates)
+
+		if !errors.Is(err, ErrNoRate) {
+			t.Errorf("got %v want ErrNoRate", err)
+		}
+		assertBalance(t, from, BTC)
+	})
+
+	t.Run("won't send an amount worth nothing", func(t *testing.T) {
+		from, to := NewWallet(BTC), &Wallet{}
+
+		_, err := TransferConverted(from, to, Satoshi.Money(), CurrencyUSD, rates)
+
+		if !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("got %v want ErrInvalidAmount", err)
+		}
+		assertBalance(t, from, BTC)
+	})
+}
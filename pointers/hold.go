@@ -0,0 +1,148 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrHoldExpired   = errors.New("hold has expired")
+	ErrInvalidExpiry = errors.New("hold must expire in the future")
+)
+
+type HoldID int
+
+// Hold sets money aside for a payment that hasn't happened yet. Held
+// money stays in the balance but can't be withdrawn or held again until
+// the hold is captured, released or expires.
+type Hold struct {
+	ID      HoldID    `json:"id"`
+	Amount  Money     `json:"amount"`
+	Memo    string    `json:"memo,omitempty"`
+	Placed  time.Time `json:"placed"`
+	Expires time.Time `json:"expires"`
+}
+
+func (h Hold) expired(now time.Time) bool {
+	return !now.Before(h.Expires)
+}
+
+// PlaceHold holds amount for ttl. Holds count towards the withdrawal
+// limits when they're placed, so capturing one later can't be refused.
+func (w *Wallet) PlaceHold(amount Money, ttl time.Duration, memo string) (HoldID, error) {
+	if ttl <= 0 {
+		return 0, ErrInvalidExpiry
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := checkAmount(amount); err != nil {
+		return 0, err
+	}
+
+	now := w.clock()
+	w.forgetExpiredHolds(now)
+	if err := w.checkLimits(amount); err != nil {
+		return 0, err
+	}
+	if amount.Amount > w.available(amount.Currency) {
+		return 0, ErrInsufficientFunds
+	}
+	w.lastHold++
+	w.holds = append(w.holds, Hold{
+		ID:      w.lastHold,
+		Amount:  amount,
+		Memo:    memo,
+		Placed:  now.UTC(),
+		Expires: now.Add(ttl).UTC(),
+	})
+	return w.lastHold, nil
+}
+
+// CaptureHold withdraws the held money.
+func (w *Wallet) CaptureHold(id HoldID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	hold, err := w.removeHold(id)
+	if err != nil {
+		return err
+	}
+	w.take(hold.Amount, hold.Memo)
+	return nil
+}
+
+// ReleaseHold gives the held money back to the available balance.
+func (w *Wallet) ReleaseHold(id HoldID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.removeHold(id)
+	return err
+}
+
+// Holds returns the holds that haven't expired, oldest first.
+func (w *Wallet) Holds() []Hold {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.clock()
+	var out []Hold
+	for _, h := range w.holds {
+		if !h.expired(now) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Available is the balance in c less what's held.
+func (w *Wallet) Available(c Currency) Money {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Money{Amount: w.available(c), Currency: c}
+}
+
+// available, held, removeHold and forgetExpiredHolds work on the holds.
+// The caller holds w.mu.
+func (w *Wallet) available(c Currency) int64 {
+	return w.balances[c] - w.held(c)
+}
+
+func (w *Wallet) held(c Currency) int64 {
+	now := w.clock()
+	var total int64
+	for _, h := range w.holds {
+		if h.Amount.Currency == c && !h.expired(now) {
+			total += h.Amount.Amount
+		}
+	}
+	return total
+}
+
+// removeHold takes a hold off the wallet. An expired hold is removed
+// too, but reported as ErrHoldExpired.
+func (w *Wallet) removeHold(id HoldID) (Hold, error) {
+	for i, h := range w.holds {
+		if h.ID != id {
+			continue
+		}
+		w.holds = append(w.holds[:i], w.holds[i+1:]...)
+		if h.expired(w.clock()) {
+			return Hold{}, fmt.Errorf("%w: hold %d expired at %s", ErrHoldExpired, id, h.Expires.Format(time.RFC3339))
+		}
+		return h, nil
+	}
+	return Hold{}, fmt.Errorf("%w: %d", ErrHoldNotFound, id)
+}
+
+// forgetExpiredHolds drops holds that ran out a day or more ago, so
+// they don't pile up. Recently expired holds are kept so capturing one
+// says it expired rather than that it never existed.
+func (w *Wallet) forgetExpiredHolds(now time.Time) {
+	kept := w.holds[:0]
+	for _, h := range w.holds {
+		if now.Sub(h.Expires) < 24*time.Hour {
+			kept = append(kept, h)
+		}
+	}
+	w.holds = kept
+}
@@ -0,0 +1,118 @@
+package pointers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHolds(t *testing.T) {
+	t.Run("holds money without withdrawing it", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+
+		_, err := wallet.PlaceHold(btc(15), time.Hour, "hotel")
+
+		assertNoError(t, err)
+		assertBalance(t, wallet, Bitcoin(20))
+		assertAvailable(t, wallet, Bitcoin(5))
+		assertError(t, wallet.Withdraw(Bitcoin(10)), ErrInsufficientFunds.Error())
+		if _, err := wallet.PlaceHold(btc(10), time.Hour, ""); !errors.Is(err, ErrInsufficientFunds) {
+			t.Errorf("got %v want ErrInsufficientFunds", err)
+		}
+	})
+
+	t.Run("captures a hold", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		id, _ := wallet.PlaceHold(btc(15), time.Hour, "hotel")
+
+		assertNoError(t, wallet.CaptureHold(id))
+
+		assertBalance(t, wallet, Bitcoin(5))
+		assertAvailable(t, wallet, Bitcoin(5))
+		assertTransactions(t, wallet.Transactions(TransactionFilter{Kind: KindWithdrawal}), []Transaction{
+			{ID: 2, Kind: KindWithdrawal, Amount: btc(15), Memo: "hotel", Balance: btc(5)},
+		})
+		if err := wallet.CaptureHold(id); !errors.Is(err, ErrHoldNotFound) {
+			t.Errorf("captured twice: got %v want ErrHoldNotFound", err)
+		}
+	})
+
+	t.Run("releases a hold", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		id, _ := wallet.PlaceHold(btc(15), time.Hour, "")
+
+		assertNoError(t, wallet.ReleaseHold(id))
+
+		assertAvailable(t, wallet, Bitcoin(20))
+		if got := len(wallet.Holds()); got != 0 {
+			t.Errorf("got %d holds want none", got)
+		}
+		if err := wallet.CaptureHold(id); !errors.Is(err, ErrHoldNotFound) {
+			t.Errorf("got %v want ErrHoldNotFound", err)
+		}
+	})
+
+	t.Run("lets holds expire", func(t *testing.T) {
+		clock := &testClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+		wallet := &Wallet{now: clock.Now}
+		wallet.Deposit(Bitcoin(20))
+		id, _ := wallet.PlaceHold(btc(15), time.Hour, "")
+
+		clock.Advance(time.Hour)
+
+		assertAvailable(t, wallet, Bitcoin(20))
+		if err := wallet.CaptureHold(id); !errors.Is(err, ErrHoldExpired) {
+			t.Errorf("got %v want ErrHoldExpired", err)
+		}
+		assertBalance(t, wallet, Bitcoin(20))
+	})
+
+	t.Run("keeps currencies apart", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.DepositMoney(Money{Amount: 1000, Currency: CurrencyUSD}, "")
+
+		wallet.PlaceHold(Money{Amount: 1000, Currency: CurrencyUSD}, time.Hour, "")
+
+		assertAvailable(t, wallet, Bitcoin(20))
+		if got := wallet.Available(CurrencyUSD).Amount; got != 0 {
+			t.Errorf("got %d cents available want none", got)
+		}
+	})
+
+	t.Run("rejects bad holds", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+
+		if _, err := wallet.PlaceHold(btc(5), 0, ""); !errors.Is(err, ErrInvalidExpiry) {
+			t.Errorf("got %v want ErrInvalidExpiry", err)
+		}
+		if _, err := wallet.PlaceHold(btc(-5), time.Hour, ""); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("got %v want ErrInvalidAmount", err)
+		}
+	})
+
+	t.Run("stops transfers spending held money", func(t *testing.T) {
+		from, to := NewWallet(Bitcoin(20)), &Wallet{}
+		from.PlaceHold(btc(15), time.Hour, "")
+
+		assertError(t, Transfer(from, to, Bitcoin(10)), ErrInsufficientFunds.Error())
+	})
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func assertAvailable(t *testing.T, wallet *Wallet, want Bitcoin) {
+	t.Helper()
+	if got := Bitcoin(wallet.Available(CurrencyBTC).Amount); got != want {
+		t.Errorf("got %s available want %s", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrLedgerMismatch = errors.New("ledger does not add up")
+
+type TransactionKind string
+
+const (
+	KindDeposit    TransactionKind = "deposit"
+	KindWithdrawal TransactionKind = "withdrawal"
+)
+
+// Transaction is one entry in a wallet's ledger. Balance is the wallet's
+// balance in the same currency straight after it.
+type Transaction struct {
+	ID      int
+	Time    time.Time
+	Kind    TransactionKind
+	Amount  Money
+	Memo    string
+	Balance Money
+}
+
+// TransactionFilter picks ledger entries. Zero fields match everything.
+type TransactionFilter struct {
+	Kind         TransactionKind
+	Currency     Currency
+	Since        time.Time
+	Until        time.Time
+	MemoContains string
+}
+
+func (f TransactionFilter) matches(tx Transaction) bool {
+	switch {
+	case f.Kind != "" && tx.Kind != f.Kind:
+		return false
+	case f.Currency != "" && tx.Amount.Currency != f.Currency:
+		return false
+	case !f.Since.IsZero() && tx.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !tx.Time.Before(f.Until):
+		return false
+	case f.MemoContains != "" && !strings.Contains(tx.Memo, f.MemoContains):
+		return false
+	}
+	return true
+}
+
+// Replay works out the balances from scratch by applying every entry in
+// order, checking each one's recorded balance on the way.
+func Replay(ledger []Transaction) (Balances, error) {
+	balances := make(Balances)
+	for _, tx := range ledger {
+		c := tx.Amount.Currency
+		balance := balances[c]
+		var err error
+		switch {
+		case tx.Balance.Currency != c:
+			err = fmt.Errorf("balance in %s for an amount in %s", tx.Balance.Currency, c)
+		case tx.Kind == KindDeposit:
+			balance, err = addInt64(balance, tx.Amount.Amount)
+		case tx.Kind == KindWithdrawal:
+			balance, err = subInt64(balance, tx.Amount.Amount)
+		default:
+			err = fmt.Errorf("unknown kind %q", tx.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: transaction %d: %v", ErrLedgerMismatch, tx.ID, err)
+		}
+		if balance != tx.Balance.Amount {
+			got := Money{Amount: balance, Currency: c}
+			return nil, fmt.Errorf("%w: transaction %d records %s but replay gives %s", ErrLedgerMismatch, tx.ID, tx.Balance, got)
+		}
+		balances[c] = balance
+	}
+	return balances, nil
+}
+
+// record appends a ledger entry. The caller holds w.mu.
+func (w *Wallet) record(kind TransactionKind, amount Money, memo string) {
+	w.ledger = append(w.ledger, Transaction{
+		ID:      len(w.ledger) + 1,
+		Time:    w.clock().UTC(),
+		Kind:    kind,
+		Amount:  amount,
+		Memo:    memo,
+		Balance: Money{Amount: w.balances[amount.Currency], Currency: amount.Currency},
+	})
+}
+
+// Transactions returns the ledger entries that match filter, oldest first.
+func (w *Wallet) Transactions(filter TransactionFilter) []Transaction {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []Transaction
+	for _, tx := range w.ledger {
+		if filter.matches(tx) {
+			out = append(out, tx)
+		}
+	}
+	return out
+}
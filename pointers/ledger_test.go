@@ -0,0 +1,119 @@
+package pointers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	t.Run("records every change with the balance after it", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.DepositWithMemo(Bitcoin(5), "pocket money")
+		wallet.WithdrawWithMemo(Bitcoin(8), "pizza")
+
+		got := wallet.Transactions(TransactionFilter{})
+
+		want := []Transaction{
+			{ID: 1, Kind: KindDeposit, Amount: btc(20), Memo: "opening balance", Balance: btc(20)},
+			{ID: 2, Kind: KindDeposit, Amount: btc(5), Memo: "pocket money", Balance: btc(25)},
+			{ID: 3, Kind: KindWithdrawal, Amount: btc(8), Memo: "pizza", Balance: btc(17)},
+		}
+		assertTransactions(t, got, want)
+	})
+
+	t.Run("leaves failed withdrawals out", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.Withdraw(Bitcoin(100))
+
+		if got := len(wallet.Transactions(TransactionFilter{})); got != 1 {
+			t.Errorf("got %d transactions want 1", got)
+		}
+	})
+
+	t.Run("filters transactions", func(t *testing.T) {
+		wallet := &Wallet{}
+		wallet.DepositWithMemo(Bitcoin(10), "salary")
+		wallet.WithdrawWithMemo(Bitcoin(3), "coffee")
+		wallet.WithdrawWithMemo(Bitcoin(4), "more coffee")
+
+		assertTransactionIDs(t, wallet.Transactions(TransactionFilter{Kind: KindWithdrawal}), 2, 3)
+		assertTransactionIDs(t, wallet.Transactions(TransactionFilter{MemoContains: "coffee"}), 2, 3)
+		assertTransactionIDs(t, wallet.Transactions(TransactionFilter{Until: time.Now().Add(-time.Hour)}))
+
+		assertTransactionIDs(t, wallet.Transactions(TransactionFilter{Since: time.Now().Add(-time.Hour), Kind: KindDeposit}), 1)
+	})
+
+	t.Run("replays the ledger to the balance", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.Deposit(Bitcoin(7))
+		wallet.Withdraw(Bitcoin(12))
+
+		balances, err := Replay(wallet.Transactions(TransactionFilter{}))
+
+		assertNoError(t, err)
+		assertBalance(t, wallet, Bitcoin(balances[CurrencyBTC]))
+	})
+
+	t.Run("keeps a running balance per currency", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.DepositMoney(Money{Amount: 500, Currency: CurrencyUSD}, "")
+		wallet.WithdrawMoney(Money{Amount: 150, Currency: CurrencyUSD}, "")
+		wallet.Withdraw(Bitcoin(5))
+
+		usd := wallet.Transactions(TransactionFilter{Currency: CurrencyUSD})
+		assertTransactionIDs(t, usd, 2, 3)
+		if got, want := usd[1].Balance, (Money{Amount: 350, Currency: CurrencyUSD}); got != want {
+			t.Errorf("got balance %v want %v", got, want)
+		}
+
+		balances, err := Replay(wallet.Transactions(TransactionFilter{}))
+		assertNoError(t, err)
+		if balances[CurrencyBTC] != 15 || balances[CurrencyUSD] != 350 {
+			t.Errorf("replayed %v want 15 satoshis and 350 cents", balances)
+		}
+	})
+
+	t.Run("spots a ledger that doesn't add up", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+		wallet.Withdraw(Bitcoin(5))
+		ledger := wallet.Transactions(TransactionFilter{})
+		ledger[1].Amount.Amount = 6
+
+		if _, err := Replay(ledger); !errors.Is(err, ErrLedgerMismatch) {
+			t.Errorf("got %v want ErrLedgerMismatch", err)
+		}
+	})
+}
+
+func btc(amount Bitcoin) Money {
+	return amount.Money()
+}
+
+func assertTransactions(t *testing.T, got, want []Transaction) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Time.IsZero() {
+			t.Errorf("transaction %d has no time", got[i].ID)
+		}
+		got[i].Time = time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("got %+v want %+v", got[i], want[i])
+		}
+	}
+}
+
+func assertTransactionIDs(t *testing.T, got []Transaction, want ...int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].ID != want[i] {
+			t.Errorf("got transaction %d want %d", got[i].ID, want[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLimitExceeded matches both TransactionLimitError and
+// DailyLimitError.
+var ErrLimitExceeded = errors.New("withdrawal limit exceeded")
+
+// Limits caps the money leaving a wallet, per currency, through
+// withdrawals, transfers out and holds. A currency without an entry has
+// no limit. The daily limit resets at midnight UTC.
+type Limits struct {
+	PerTransaction map[Currency]int64 `json:"perTransaction,omitempty"`
+	Daily          map[Currency]int64 `json:"daily,omitempty"`
+}
+
+// TransactionLimitError reports a single withdrawal over the limit.
+type TransactionLimitError struct {
+	Limit  Money
+	Amount Money
+}
+
+func (e *TransactionLimitError) Error() string {
+	return fmt.Sprintf("%s is over the limit of %s per withdrawal", e.Amount, e.Limit)
+}
+
+func (e *TransactionLimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// DailyLimitError reports a withdrawal that would take the day's total,
+// counting money on hold, over the limit.
+type DailyLimitError struct {
+	Limit  Money
+	Used   Money
+	Amount Money
+}
+
+func (e *DailyLimitError) Error() string {
+	return fmt.Sprintf("%s would take today's withdrawals from %s past the daily limit of %s", e.Amount, e.Used, e.Limit)
+}
+
+func (e *DailyLimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// SetLimits replaces the wallet's withdrawal limits.
+func (w *Wallet) SetLimits(limits Limits) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.limits = Limits{PerTransaction: copyLimits(limits.PerTransaction), Daily: copyLimits(limits.Daily)}
+}
+
+func (w *Wallet) Limits() Limits {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Limits{PerTransaction: copyLimits(w.limits.PerTransaction), Daily: copyLimits(w.limits.Daily)}
+}
+
+func copyLimits(limits map[Currency]int64) map[Currency]int64 {
+	if limits == nil {
+		return nil
+	}
+	out := make(map[Currency]int64, len(limits))
+	for c, amount := range limits {
+		out[c] = amount
+	}
+	return out
+}
+
+// checkLimits says whether m may leave the wallet. The caller holds w.mu.
+func (w *Wallet) checkLimits(m Money) error {
+	c := m.Currency
+	if limit, ok := w.limits.PerTransaction[c]; ok && m.Amount > limit {
+		return &TransactionLimitError{Limit: Money{Amount: limit, Currency: c}, Amount: m}
+	}
+	limit, ok := w.limits.Daily[c]
+	if !ok {
+		return nil
+	}
+	used := w.withdrawnToday(c) + w.held(c)
+	if m.Amount > limit-used {
+		return &DailyLimitError{
+			Limit:  Money{Amount: limit, Currency: c},
+			Used:   Money{Amount: used, Currency: c},
+			Amount: m,
+		}
+	}
+	return nil
+}
+
+// withdrawnToday adds up the withdrawals in c since midnight UTC. The
+// caller holds w.mu.
+func (w *Wallet) withdrawnToday(c Currency) int64 {
+	midnight := w.clock().UTC().Truncate(24 * time.Hour)
+	var total int64
+	for i := len(w.ledger) - 1; i >= 0 && !w.ledger[i].Time.Before(midnight); i-- {
+		if tx := w.ledger[i]; tx.Kind == KindWithdrawal && tx.Amount.Currency == c {
+			total += tx.Amount.Amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,83 @@
+package pointers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("caps a single withdrawal", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(100))
+		wallet.SetLimits(Limits{PerTransaction: map[Currency]int64{CurrencyBTC: 30}})
+
+		err := wallet.Withdraw(Bitcoin(31))
+
+		var limitErr *TransactionLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("got %v want a TransactionLimitError", err)
+		}
+		if limitErr.Limit != btc(30) || limitErr.Amount != btc(31) {
+			t.Errorf("got %+v", limitErr)
+		}
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Error("doesn't match ErrLimitExceeded")
+		}
+		assertNoError(t, wallet.Withdraw(Bitcoin(30)))
+	})
+
+	t.Run("caps the day's withdrawals, holds and transfers", func(t *testing.T) {
+		clock := &testClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+		wallet := &Wallet{now: clock.Now}
+		wallet.Deposit(Bitcoin(100))
+		wallet.SetLimits(Limits{Daily: map[Currency]int64{CurrencyBTC: 50}})
+
+		assertNoError(t, wallet.Withdraw(Bitcoin(20)))
+		assertNoError(t, Transfer(wallet, &Wallet{}, Bitcoin(10)))
+		_, err := wallet.PlaceHold(btc(15), time.Hour, "")
+		assertNoError(t, err)
+
+		err = wallet.Withdraw(Bitcoin(6))
+
+		var limitErr *DailyLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("got %v want a DailyLimitError", err)
+		}
+		if limitErr.Used != btc(45) {
+			t.Errorf("got %s used want %s", limitErr.Used, btc(45))
+		}
+		assertNoError(t, wallet.Withdraw(Bitcoin(5)))
+	})
+
+	t.Run("resets at midnight UTC", func(t *testing.T) {
+		clock := &testClock{now: time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)}
+		wallet := &Wallet{now: clock.Now}
+		wallet.Deposit(Bitcoin(100))
+		wallet.SetLimits(Limits{Daily: map[Currency]int64{CurrencyBTC: 50}})
+		wallet.Withdraw(Bitcoin(50))
+
+		clock.Advance(2 * time.Hour)
+
+		assertNoError(t, wallet.Withdraw(Bitcoin(50)))
+	})
+
+	t.Run("lets captures through once the hold was allowed", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(100))
+		id, _ := wallet.PlaceHold(btc(40), time.Hour, "")
+
+		wallet.SetLimits(Limits{PerTransaction: map[Currency]int64{CurrencyBTC: 10}})
+
+		assertNoError(t, wallet.CaptureHold(id))
+	})
+
+	t.Run("only limits the currencies it names", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(100))
+		wallet.DepositMoney(Money{Amount: 10_000, Currency: CurrencyUSD}, "")
+		wallet.SetLimits(Limits{PerTransaction: map[Currency]int64{CurrencyUSD: 500}})
+
+		assertNoError(t, wallet.Withdraw(Bitcoin(100)))
+		if err := wallet.WithdrawMoney(Money{Amount: 501, Currency: CurrencyUSD}, ""); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("got %v want ErrLimitExceeded", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+type Currency string
+
+const (
+	CurrencyBTC Currency = "BTC"
+	CurrencyETH Currency = "ETH"
+	CurrencyUSD Currency = "USD"
+	CurrencyEUR Currency = "EUR"
+)
+
+// currencyDecimals is how many decimal places each currency's smallest
+// unit sits below one whole unit. ETH stops at gwei so that amounts
+// still fit in an int64.
+var currencyDecimals = map[Currency]int{
+	CurrencyBTC: 8,
+	CurrencyETH: 9,
+	CurrencyUSD: 2,
+	CurrencyEUR: 2,
+}
+
+func (c Currency) Decimals() (int, error) {
+	d, ok := currencyDecimals[c]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, c)
+	}
+	return d, nil
+}
+
+// Money is an amount of a currency, counted in its smallest unit:
+// satoshis, gwei or cents.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// ParseMoney reads an amount followed by its currency, such as
+// "12.50 USD".
+func ParseMoney(s string) (Money, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+	}
+	currency := Currency(strings.ToUpper(fields[1]))
+	decimals, err := currency.Decimals()
+	if err != nil {
+		return Money{}, err
+	}
+	amount, err := parseMinorUnits(fields[0], decimals)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", err, s)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) String() string {
+	decimals, err := m.Currency.Decimals()
+	if err != nil {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	return formatMinorUnits(m.Amount, decimals) + " " + string(m.Currency)
+}
+
+// Balances is what a wallet holds, one amount per currency.
+type Balances map[Currency]int64
+
+// List returns the non-zero balances sorted by currency.
+func (b Balances) List() []Money {
+	var out []Money
+	for c, amount := range b {
+		if amount != 0 {
+			out = append(out, Money{Amount: amount, Currency: c})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Currency < out[j].Currency })
+	return out
+}
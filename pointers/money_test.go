@@ -0,0 +1,84 @@
+package pointers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]Money{
+		"12.50 USD":       {Amount: 1250, Currency: CurrencyUSD},
+		"0.000000001 ETH": {Amount: 1, Currency: CurrencyETH},
+		"3 eur":           {Amount: 300, Currency: CurrencyEUR},
+		"0.5 BTC":         (BTC / 2).Money(),
+	}
+	for in, want := range cases {
+		t.Run(in, func(t *testing.T) {
+			got, err := ParseMoney(in)
+			assertNoError(t, err)
+			if got != want {
+				t.Errorf("got %+v want %+v", got, want)
+			}
+			if again, _ := ParseMoney(got.String()); again != got {
+				t.Errorf("%s parsed back as %+v", got, again)
+			}
+		})
+	}
+
+	t.Run("rejects amounts finer than the currency", func(t *testing.T) {
+		if _, err := ParseMoney("1.001 USD"); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("got %v want ErrInvalidFormat", err)
+		}
+	})
+
+	t.Run("rejects unknown currencies", func(t *testing.T) {
+		if _, err := ParseMoney("1 DOGE"); !errors.Is(err, ErrUnknownCurrency) {
+			t.Errorf("got %v want ErrUnknownCurrency", err)
+		}
+	})
+}
+
+func TestMultiCurrencyWallet(t *testing.T) {
+	t.Run("keeps each currency apart", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(10))
+		assertNoError(t, wallet.DepositMoney(Money{Amount: 2500, Currency: CurrencyUSD}, "pay"))
+		assertNoError(t, wallet.WithdrawMoney(Money{Amount: 500, Currency: CurrencyUSD}, "lunch"))
+
+		assertBalance(t, wallet, Bitcoin(10))
+		want := []Money{Bitcoin(10).Money(), {Amount: 2000, Currency: CurrencyUSD}}
+		if got := wallet.Balances().List(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("won't pay one currency out of another", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(10))
+
+		err := wallet.WithdrawMoney(Money{Amount: 1, Currency: CurrencyEUR}, "")
+
+		assertError(t, err, ErrInsufficientFunds.Error())
+	})
+
+	t.Run("rejects unknown currencies", func(t *testing.T) {
+		wallet := &Wallet{}
+
+		err := wallet.DepositMoney(Money{Amount: 1, Currency: "DOGE"}, "")
+
+		if !errors.Is(err, ErrUnknownCurrency) {
+			t.Errorf("got %v want ErrUnknownCurrency", err)
+		}
+	})
+
+	t.Run("transfers in any currency", func(t *testing.T) {
+		from, to := &Wallet{}, &Wallet{}
+		from.DepositMoney(Money{Amount: 3_000_000_000, Currency: CurrencyETH}, "")
+
+		err := TransferMoney(from, to, Money{Amount: 1_000_000_000, Currency: CurrencyETH})
+
+		assertNoError(t, err)
+		if got := to.BalanceOf(CurrencyETH).String(); got != "1 ETH" {
+			t.Errorf("got %s want 1 ETH", got)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package pointers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrWalletNotFound  = errors.New("wallet not found")
+	ErrVersionConflict = errors.New("wallet was changed by someone else")
+	ErrDuplicateWallet = errors.New("wallet given twice")
+)
+
+// WalletRepository keeps wallets beyond the life of the process.
+//
+// Every saved wallet has a version. Load returns a separate copy of the
+// wallet at its current version, and Save only accepts a wallet whose
+// version still matches what's stored, so when two copies are changed
+// at once the second save fails with ErrVersionConflict instead of
+// overwriting the first. Save stores all the wallets it is given or none
+// of them, so both sides of a transfer can be saved together.
+type WalletRepository interface {
+	Load(id uint64) (*Wallet, error)
+	Save(wallets ...*Wallet) error
+}
+
+// Version is the stored version the wallet was loaded or last saved at,
+// or zero if it has never been saved.
+func (w *Wallet) Version() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.version
+}
+
+// walletState is what's stored for a wallet.
+type walletState struct {
+	ID       uint64        `json:"id"`
+	Version  uint64        `json:"version"`
+	Balances Balances      `json:"balances"`
+	Ledger   []Transaction `json:"ledger"`
+	Holds    []Hold        `json:"holds,omitempty"`
+	LastHold HoldID        `json:"lastHold,omitempty"`
+	Limits   Limits        `json:"limits"`
+}
+
+// state copies the wallet out. The caller holds w.mu.
+func (w *Wallet) state() walletState {
+	s := walletState{
+		ID:       w.ID(),
+		Version:  w.version,
+		Balances: make(Balances, len(w.balances)),
+		Ledger:   append([]Transaction(nil), w.ledger...),
+		Holds:    append([]Hold(nil), w.holds...),
+		LastHold: w.lastHold,
+		Limits:   Limits{PerTransaction: copyLimits(w.limits.PerTransaction), Daily: copyLimits(w.limits.Daily)},
+	}
+	for c, amount := range w.balances {
+		s.Balances[c] = amount
+	}
+	return s
+}
+
+func restoreWallet(s walletState) *Wallet {
+	w := &Wallet{
+		balances: make(Balances, len(s.Balances)),
+		ledger:   append([]Transaction(nil), s.Ledger...),
+		version:  s.Version,
+		holds:    append([]Hold(nil), s.Holds...),
+		lastHold: s.LastHold,
+		limits:   Limits{PerTransaction: copyLimits(s.Limits.PerTransaction), Daily: copyLimits(s.Limits.Daily)},
+	}
+	for c, amount := range s.Balances {
+		w.balances[c] = amount
+	}
+	w.id.Store(s.ID)
+	reserveWalletID(s.ID)
+	return w
+}
+
+// walletTable is the versioned table both repositories keep in memory.
+// persist, when set, must durably store the table before a save counts.
+type walletTable struct {
+	mu      sync.Mutex
+	wallets map[uint64]walletState
+	persist func(map[uint64]walletState) error
+}
+
+func (t *walletTable) load(id uint64) (*Wallet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.wallets[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrWalletNotFound, id)
+	}
+	return restoreWallet(s), nil
+}
+
+func (t *walletTable) save(wallets []*Wallet) error {
+	// Lock the wallets the same way Transfer does, so a save can't see
+	// half a transfer or deadlock against one.
+	ordered := append([]*Wallet(nil), wallets...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ID() < ordered[j].ID() })
+	for i := 1; i < len(ordered); i++ {
+		// Two copies of one wallet can't both be saved: whichever went
+		// second would silently undo the first.
+		if ordered[i] != ordered[i-1] && ordered[i].ID() == ordered[i-1].ID() {
+			return fmt.Errorf("%w: wallet %d", ErrDuplicateWallet, ordered[i].ID())
+		}
+	}
+	for i, w := range ordered {
+		if i > 0 && w == ordered[i-1] {
+			continue
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next := make(map[uint64]walletState, len(t.wallets)+len(ordered))
+	for id, s := range t.wallets {
+		next[id] = s
+	}
+	for i, w := range ordered {
+		if i > 0 && w == ordered[i-1] {
+			continue
+		}
+		s := w.state()
+		if stored := t.wallets[s.ID].Version; stored != s.Version {
+			return fmt.Errorf("%w: wallet %d is at version %d, not %d", ErrVersionConflict, s.ID, stored, s.Version)
+		}
+		s.Version++
+		next[s.ID] = s
+	}
+	if t.persist != nil {
+		if err := t.persist(next); err != nil {
+			return err
+		}
+	}
+	t.wallets = next
+	for _, w := range ordered {
+		w.version = next[w.ID()].Version
+	}
+	return nil
+}
+
+// InMemoryWalletRepository keeps wallets for as long as the process
+// runs, which is handy in tests.
+type InMemoryWalletRepository struct {
+	table walletTable
+}
+
+func NewInMemoryWalletRepository() *InMemoryWalletRepository {
+	return &InMemoryWalletRepository{table: walletTable{wallets: make(map[uint64]walletState)}}
+}
+
+func (r *InMemoryWalletRepository) Load(id uint64) (*Wallet, error) {
+	return r.table.load(id)
+}
+
+func (r *InMemoryWalletRepository) Save(wallets ...*Wallet) error {
+	return r.table.save(wallets)
+}
+
+// FileWalletRepository keeps every wallet in one JSON file, rewritten
+// in full on each save. The new file is written next to the old one and
+// renamed over it, so a crash leaves one or the other, never half of
+// each.
+type FileWalletRepository struct {
+	path  string
+	table walletTable
+}
+
+// NewFileWalletRepository opens the wallets stored at path, creating
+// the file on the first save if it doesn't exist yet.
+func NewFileWalletRepository(path string) (*FileWalletRepository, error) {
+	r := &FileWalletRepository{path: path}
+	r.table.wallets = make(map[uint64]walletState)
+	r.table.persist = r.write
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []walletState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("reading wallets from %s: %w", path, err)
+	}
+	for _, s := range stored {
+		r.table.wallets[s.ID] = s
+		reserveWalletID(s.ID)
+	}
+	return r, nil
+}
+
+func (r *FileWalletRepository) Load(id uint64) (*Wallet, error) {
+	return r.table.load(id)
+}
+
+func (r *FileWalletRepository) Save(wallets ...*Wallet) error {
+	return r.table.save(wallets)
+}
+
+func (r *FileWalletRepository) write(wallets map[uint64]walletState) error {
+	stored := make([]walletState, 0, len(wallets))
+	for _, s := range wallets {
+		stored = append(stored, s)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := r.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(r.path))
+}
+
+// syncDir flushes a directory, so a rename into it survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
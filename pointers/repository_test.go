@@ -0,0 +1,203 @@
+package pointers
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWalletRepositories(t *testing.T) {
+	repos := map[string]func(t *testing.T) WalletRepository{
+		"memory": func(t *testing.T) WalletRepository {
+			return NewInMemoryWalletRepository()
+		},
+		"file": func(t *testing.T) WalletRepository {
+			repo, err := NewFileWalletRepository(filepath.Join(t.TempDir(), "wallets.json"))
+			assertNoError(t, err)
+			return repo
+		},
+	}
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testWalletRepository(t, newRepo)
+		})
+	}
+}
+
+func testWalletRepository(t *testing.T, newRepo func(t *testing.T) WalletRepository) {
+	t.Run("loads what was saved", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := NewWallet(Bitcoin(20))
+		wallet.DepositMoney(Money{Amount: 500, Currency: CurrencyUSD}, "pay")
+		assertNoError(t, repo.Save(wallet))
+
+		got, err := repo.Load(wallet.ID())
+
+		assertNoError(t, err)
+		assertSameWallet(t, got, wallet)
+		if got.Version() != 1 || wallet.Version() != 1 {
+			t.Errorf("got versions %d and %d want 1", got.Version(), wallet.Version())
+		}
+	})
+
+	t.Run("hands out copies", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := NewWallet(Bitcoin(20))
+		assertNoError(t, repo.Save(wallet))
+
+		wallet.Deposit(Bitcoin(5))
+		got, _ := repo.Load(wallet.ID())
+
+		assertBalance(t, got, Bitcoin(20))
+	})
+
+	t.Run("rejects a stale copy", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := NewWallet(Bitcoin(20))
+		assertNoError(t, repo.Save(wallet))
+		first, _ := repo.Load(wallet.ID())
+		second, _ := repo.Load(wallet.ID())
+
+		first.Withdraw(Bitcoin(15))
+		second.Withdraw(Bitcoin(15))
+		assertNoError(t, repo.Save(first))
+		err := repo.Save(second)
+
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("got %v want ErrVersionConflict", err)
+		}
+		got, _ := repo.Load(wallet.ID())
+		assertBalance(t, got, Bitcoin(5))
+		if second.Version() != 1 {
+			t.Errorf("rejected wallet moved to version %d", second.Version())
+		}
+	})
+
+	t.Run("saves all wallets or none", func(t *testing.T) {
+		repo := newRepo(t)
+		from, to := NewWallet(Bitcoin(20)), &Wallet{}
+		assertNoError(t, repo.Save(from, to))
+		stale, _ := repo.Load(to.ID())
+		stale.Deposit(Bitcoin(1))
+		assertNoError(t, repo.Save(stale))
+
+		Transfer(from, to, Bitcoin(5))
+		err := repo.Save(from, to)
+
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("got %v want ErrVersionConflict", err)
+		}
+		got, _ := repo.Load(from.ID())
+		assertBalance(t, got, Bitcoin(20))
+	})
+
+	t.Run("keeps holds and limits", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := NewWallet(Bitcoin(20))
+		id, _ := wallet.PlaceHold(btc(15), time.Hour, "hotel")
+		wallet.SetLimits(Limits{Daily: map[Currency]int64{CurrencyBTC: 18}})
+		assertNoError(t, repo.Save(wallet))
+
+		got, _ := repo.Load(wallet.ID())
+
+		assertAvailable(t, got, Bitcoin(5))
+		if _, err := got.PlaceHold(btc(4), time.Hour, ""); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("got %v want ErrLimitExceeded", err)
+		}
+		assertNoError(t, got.CaptureHold(id))
+	})
+
+	t.Run("refuses two copies of one wallet", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := NewWallet(Bitcoin(20))
+		assertNoError(t, repo.Save(wallet))
+		first, _ := repo.Load(wallet.ID())
+		second, _ := repo.Load(wallet.ID())
+		first.Withdraw(Bitcoin(5))
+		second.Withdraw(Bitcoin(10))
+
+		err := repo.Save(first, second)
+
+		if !errors.Is(err, ErrDuplicateWallet) {
+			t.Fatalf("got %v want ErrDuplicateWallet", err)
+		}
+		got, _ := repo.Load(wallet.ID())
+		assertBalance(t, got, Bitcoin(20))
+		assertNoError(t, repo.Save(first, first))
+	})
+
+	t.Run("doesn't find wallets it never saved", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.Load((&Wallet{}).ID()); !errors.Is(err, ErrWalletNotFound) {
+			t.Errorf("got %v want ErrWalletNotFound", err)
+		}
+	})
+}
+
+func TestFileWalletRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+
+	t.Run("survives a restart", func(t *testing.T) {
+		repo, err := NewFileWalletRepository(path)
+		assertNoError(t, err)
+		wallet := NewWallet(Bitcoin(20))
+		wallet.WithdrawWithMemo(Bitcoin(3), "coffee")
+		assertNoError(t, repo.Save(wallet))
+
+		reopened, err := NewFileWalletRepository(path)
+		assertNoError(t, err)
+		got, err := reopened.Load(wallet.ID())
+
+		assertNoError(t, err)
+		assertSameWallet(t, got, wallet)
+		assertLedgerBalances(t, got)
+	})
+
+	t.Run("keeps new wallets clear of stored IDs", func(t *testing.T) {
+		repo, err := NewFileWalletRepository(path)
+		assertNoError(t, err)
+		wallet := &Wallet{}
+		assertNoError(t, repo.Save(wallet))
+
+		// Pretend the process restarted and forgot which IDs it gave out.
+		lastWalletID.Store(0)
+		if _, err := NewFileWalletRepository(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if id := (&Wallet{}).ID(); id <= wallet.ID() {
+			t.Errorf("new wallet got ID %d, already stored up to %d", id, wallet.ID())
+		}
+	})
+}
+
+func assertSameWallet(t *testing.T, got, want *Wallet) {
+	t.Helper()
+	if got.ID() != want.ID() {
+		t.Errorf("got wallet %d want %d", got.ID(), want.ID())
+	}
+	gotBalances, wantBalances := got.Balances().List(), want.Balances().List()
+	if len(gotBalances) != len(wantBalances) {
+		t.Fatalf("got balances %v want %v", gotBalances, wantBalances)
+	}
+	for i := range gotBalances {
+		if gotBalances[i] != wantBalances[i] {
+			t.Errorf("got balances %v want %v", gotBalances, wantBalances)
+		}
+	}
+	gotLedger, wantLedger := got.Transactions(TransactionFilter{}), want.Transactions(TransactionFilter{})
+	if len(gotLedger) != len(wantLedger) {
+		t.Fatalf("got %d transactions want %d", len(gotLedger), len(wantLedger))
+	}
+	for i := range gotLedger {
+		if !gotLedger[i].Time.Equal(wantLedger[i].Time) {
+			t.Errorf("transaction %d at %v want %v", gotLedger[i].ID, gotLedger[i].Time, wantLedger[i].Time)
+		}
+		gotLedger[i].Time = wantLedger[i].Time
+		if gotLedger[i] != wantLedger[i] {
+			t.Errorf("got %+v want %+v", gotLedger[i], wantLedger[i])
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package pointers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxConflictRetries is how many times a request is redone from a
+	// fresh load when someone else saved the wallet first.
+	maxConflictRetries = 10
+
+	maxRequestBytes = 1 << 16
+)
+
+// WalletServer serves wallets from a repository over HTTP:
+//
+//	POST /wallets                 open a wallet, optionally with an opening balance
+//	GET  /wallets/{id}            show its balances
+//	POST /wallets/{id}/deposit    pay in an amount
+//	POST /wallets/{id}/withdraw   take out an amount
+//	POST /wallets/{id}/transfer   move an amount to another wallet
+//
+// Amounts are written like "0.5 BTC". A POST carrying an Idempotency-Key
+// header is carried out once; repeating it replays the first answer
+// instead of moving the money again. Keys are remembered for a day, in
+// this process's memory only: they are lost on a restart and aren't
+// shared between servers, so a repeat sent after either is carried out
+// again.
+type WalletServer struct {
+	repo WalletRepository
+
+	mu             sync.Mutex
+	idempotent     map[string]*idempotentResponse
+	idempotencyTTL time.Duration
+	// expiring lists the keys oldest first, so expired ones are found
+	// without looking at the rest.
+	expiring []idempotencyKey
+
+	http.Handler
+}
+
+func NewWalletServer(repo WalletRepository) *WalletServer {
+	s := &WalletServer{
+		repo:           repo,
+		idempotent:     make(map[string]*idempotentResponse),
+		idempotencyTTL: 24 * time.Hour,
+	}
+	router := http.NewServeMux()
+	router.Handle("/wallets", s.idempotency(http.HandlerFunc(s.walletsHandler)))
+	router.Handle("/wallets/", s.idempotency(http.HandlerFunc(s.walletsHandler)))
+	s.Handler = router
+	return s
+}
+
+type walletResponse struct {
+	ID       uint64              `json:"id"`
+	Version  uint64              `json:"version"`
+	Balances map[Currency]string `json:"balances"`
+}
+
+type transferResponse struct {
+	From walletResponse `json:"from"`
+	To   walletResponse `json:"to"`
+}
+
+func newWalletResponse(w *Wallet) walletResponse {
+	res := walletResponse{ID: w.ID(), Version: w.Version(), Balances: make(map[Currency]string)}
+	for _, m := range w.Balances().List() {
+		decimals, _ := m.Currency.Decimals()
+		res.Balances[m.Currency] = formatMinorUnits(m.Amount, decimals)
+	}
+	return res
+}
+
+type openRequest struct {
+	Opening string `json:"opening"`
+}
+
+type amountRequest struct {
+	Amount string `json:"amount"`
+	Memo   string `json:"memo"`
+}
+
+type transferRequest struct {
+	To     uint64 `json:"to"`
+	Amount string `json:"amount"`
+}
+
+func (s *WalletServer) walletsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/wallets"), "/")
+	if path == "" {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.openWallet(w, r)
+		return
+	}
+
+	idText, action, _ := strings.Cut(path, "/")
+	id, err := strconv.ParseUint(idText, 10, 64)
+	if err != nil || id == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		wallet, err := s.repo.Load(id)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newWalletResponse(wallet))
+	case action == "deposit" || action == "withdraw":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.moveMoney(w, r, id, action)
+	case action == "transfer":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.transfer(w, r, id)
+	case action == "":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *WalletServer) openWallet(w http.ResponseWriter, r *http.Request) {
+	var req openRequest
+	if err := readJSON(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wallet := &Wallet{}
+	if req.Opening != "" {
+		opening, err := ParseMoney(req.Opening)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+		if err := wallet.DepositMoney(opening, "opening balance"); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+	}
+	if err := s.repo.Save(wallet); err != nil {
+		writeWalletError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/wallets/%d", wallet.ID()))
+	writeJSON(w, http.StatusCreated, newWalletResponse(wallet))
+}
+
+func (s *WalletServer) moveMoney(w http.ResponseWriter, r *http.Request, id uint64, action string) {
+	var req amountRequest
+	if err := readJSON(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	amount, err := ParseMoney(req.Amount)
+	if err != nil {
+		writeWalletError(w, err)
+		return
+	}
+
+	var wallet *Wallet
+	err = s.update(func() error {
+		if wallet, err = s.repo.Load(id); err != nil {
+			return err
+		}
+		if action == "deposit" {
+			err = wallet.DepositMoney(amount, req.Memo)
+		} else {
+			err = wallet.WithdrawMoney(amount, req.Memo)
+		}
+		if err != nil {
+			return err
+		}
+		return s.repo.Save(wallet)
+	})
+	if err != nil {
+		writeWalletError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newWalletResponse(wallet))
+}
+
+func (s *WalletServer) transfer(w http.ResponseWriter, r *http.Request, id uint64) {
+	var req transferRequest
+	if err := readJSON(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	amount, err := ParseMoney(req.Amount)
+	if err != nil {
+		writeWalletError(w, err)
+		return
+	}
+	if req.To == id {
+		writeWalletError(w, ErrSameWallet)
+		return
+	}
+
+	var from, to *Wallet
+	err = s.update(func() error {
+		if from, err = s.repo.Load(id); err != nil {
+			return err
+		}
+		if to, err = s.repo.Load(req.To); err != nil {
+			return err
+		}
+		if err := TransferMoney(from, to, amount); err != nil {
+			return err
+		}
+		return s.repo.Save(from, to)
+	})
+	if err != nil {
+		writeWalletError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transferResponse{From: newWalletResponse(from), To: newWalletResponse(to)})
+}
+
+// update runs a load, change and save, starting again from a fresh load
+// whenever the save loses a race with another request.
+func (s *WalletServer) update(attempt func() error) error {
+	var err error
+	for i := 0; i < maxConflictRetries; i++ {
+		if err = attempt(); !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeWalletError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrWalletNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrOverflow), errors.Is(err, ErrLimitExceeded):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrInvalidFormat),
+		errors.Is(err, ErrUnknownCurrency), errors.Is(err, ErrSameWallet):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// idempotentResponse is the first answer to a request with an
+// Idempotency-Key. done is closed once it has been filled in.
+type idempotentResponse struct {
+	request [sha256.Size]byte
+	at      time.Time
+	done    chan struct{}
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotency answers a repeated POST with the response to the first
+// one. Repeats that arrive while the first is still running wait for
+// it. Reusing a key for a different request is refused, and server
+// errors aren't remembered so the request can be tried again.
+func (s *WalletServer) idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		request := sha256.Sum256([]byte(r.URL.Path + "\n" + string(body)))
+
+		s.mu.Lock()
+		s.forgetExpiredKeys()
+		first, seen := s.idempotent[key]
+		if !seen {
+			first = &idempotentResponse{request: request, at: time.Now(), done: make(chan struct{})}
+			s.idempotent[key] = first
+			s.expiring = append(s.expiring, idempotencyKey{key, first})
+		}
+		s.mu.Unlock()
+
+		if seen {
+			if first.request != request {
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			<-first.done
+			for name, values := range first.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(first.status)
+			w.Write(first.body)
+			return
+		}
+
+		// If next panics the waiting repeats get a server error, and the
+		// key is let go so the request can be tried again.
+		first.status = http.StatusInternalServerError
+		defer func() {
+			if first.status >= http.StatusInternalServerError {
+				s.mu.Lock()
+				delete(s.idempotent, key)
+				s.mu.Unlock()
+			}
+			close(first.done)
+		}()
+		buf := &responseBuffer{header: make(http.Header)}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+		first.status, first.header, first.body = buf.status, buf.header, buf.body.Bytes()
+
+		for name, values := range first.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(first.status)
+		w.Write(first.body)
+	})
+}
+
+// responseBuffer holds on to a response so it can be replayed later.
+type responseBuffer struct {
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+type idempotencyKey struct {
+	key string
+	res *idempotentResponse
+}
+
+// forgetExpiredKeys drops answers older than the TTL, stopping at the
+// first that is still fresh or still running. The caller holds s.mu.
+func (s *WalletServer) forgetExpiredKeys() {
+	for len(s.expiring) > 0 {
+		oldest := s.expiring[0]
+		if time.Since(oldest.res.at) <= s.idempotencyTTL {
+			return
+		}
+		select {
+		case <-oldest.res.done:
+		default:
+			return
+		}
+		// The key may have been let go and used again since.
+		if s.idempotent[oldest.key] == oldest.res {
+			delete(s.idempotent, oldest.key)
+		}
+		s.expiring[0] = idempotencyKey{}
+		s.expiring = s.expiring[1:]
+	}
+}
@@ -0,0 +1,260 @@
+package pointers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWalletServer(t *testing.T) {
+	t.Run("opens a wallet and shows its balances", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+
+		res := serveWallet(server, http.MethodPost, "/wallets", `{"opening": "0.5 BTC"}`, "")
+		assertStatus(t, res, http.StatusCreated)
+		opened := decodeWallet(t, res)
+
+		res = serveWallet(server, http.MethodGet, res.Header().Get("Location"), "", "")
+		assertStatus(t, res, http.StatusOK)
+		got := decodeWallet(t, res)
+		if got.ID != opened.ID || got.Balances[CurrencyBTC] != "0.5" {
+			t.Errorf("got %+v want wallet %d with 0.5 BTC", got, opened.ID)
+		}
+	})
+
+	t.Run("deposits and withdraws", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "")
+
+		res := serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "12.50 USD", "memo": "pay"}`, "")
+		assertStatus(t, res, http.StatusOK)
+		res = serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "2.25 USD"}`, "")
+		assertStatus(t, res, http.StatusOK)
+
+		if got := decodeWallet(t, res).Balances[CurrencyUSD]; got != "10.25" {
+			t.Errorf("got %s USD want 10.25", got)
+		}
+	})
+
+	t.Run("transfers between wallets", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		from, to := openTestWallet(t, server, "1 BTC"), openTestWallet(t, server, "")
+		toID := strings.TrimPrefix(to, "/wallets/")
+
+		res := serveWallet(server, http.MethodPost, from+"/transfer", `{"to": `+toID+`, "amount": "0.25 BTC"}`, "")
+
+		assertStatus(t, res, http.StatusOK)
+		var got transferResponse
+		json.NewDecoder(res.Body).Decode(&got)
+		if got.From.Balances[CurrencyBTC] != "0.75" || got.To.Balances[CurrencyBTC] != "0.25" {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("reports errors with matching statuses", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "1 BTC")
+
+		cases := []struct {
+			name, method, path, body string
+			want                     int
+		}{
+			{"insufficient funds", http.MethodPost, id + "/withdraw", `{"amount": "2 BTC"}`, http.StatusUnprocessableEntity},
+			{"unknown wallet", http.MethodGet, "/wallets/999999", "", http.StatusNotFound},
+			{"unknown currency", http.MethodPost, id + "/deposit", `{"amount": "1 DOGE"}`, http.StatusBadRequest},
+			{"negative amount", http.MethodPost, id + "/deposit", `{"amount": "-1 BTC"}`, http.StatusBadRequest},
+			{"malformed body", http.MethodPost, id + "/deposit", `{"amount": 1}`, http.StatusBadRequest},
+			{"transfer to itself", http.MethodPost, id + "/transfer", `{"to": ` + strings.TrimPrefix(id, "/wallets/") + `, "amount": "1 BTC"}`, http.StatusBadRequest},
+			{"transfer to nowhere", http.MethodPost, id + "/transfer", `{"to": 999999, "amount": "1 BTC"}`, http.StatusNotFound},
+			{"wrong method", http.MethodDelete, id, "", http.StatusMethodNotAllowed},
+			{"unknown action", http.MethodPost, id + "/steal", "", http.StatusNotFound},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				assertStatus(t, serveWallet(server, c.method, c.path, c.body, ""), c.want)
+			})
+		}
+
+		res := serveWallet(server, http.MethodGet, id, "", "")
+		if got := decodeWallet(t, res).Balances[CurrencyBTC]; got != "1" {
+			t.Errorf("failed requests changed the balance to %s BTC", got)
+		}
+	})
+
+	t.Run("refuses withdrawals over the wallet's limits", func(t *testing.T) {
+		repo := NewInMemoryWalletRepository()
+		wallet := NewWallet(BTC)
+		wallet.SetLimits(Limits{PerTransaction: map[Currency]int64{CurrencyBTC: int64(BTC / 10)}})
+		repo.Save(wallet)
+		server := NewWalletServer(repo)
+
+		res := serveWallet(server, http.MethodPost, fmt.Sprintf("/wallets/%d/withdraw", wallet.ID()), `{"amount": "0.2 BTC"}`, "")
+
+		assertStatus(t, res, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("keeps every concurrent deposit", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "")
+
+		// Each deposit can only lose to one of the others, so all of them
+		// land within maxConflictRetries.
+		var wg sync.WaitGroup
+		for i := 0; i < maxConflictRetries; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "1 USD"}`, "")
+			}()
+		}
+		wg.Wait()
+
+		res := serveWallet(server, http.MethodGet, id, "", "")
+		if got := decodeWallet(t, res).Balances[CurrencyUSD]; got != "10" {
+			t.Errorf("got %s USD want 10", got)
+		}
+	})
+}
+
+func TestWalletServerIdempotency(t *testing.T) {
+	t.Run("moves the money once", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "1 BTC")
+
+		first := serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "0.1 BTC"}`, "key-1")
+		again := serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "0.1 BTC"}`, "key-1")
+
+		assertStatus(t, again, first.Code)
+		if again.Body.String() != first.Body.String() {
+			t.Errorf("replayed %q want %q", again.Body, first.Body)
+		}
+		if again.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("replay wasn't marked")
+		}
+		res := serveWallet(server, http.MethodGet, id, "", "")
+		if got := decodeWallet(t, res).Balances[CurrencyBTC]; got != "0.9" {
+			t.Errorf("got %s BTC want 0.9", got)
+		}
+	})
+
+	t.Run("runs concurrent repeats once", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "1 EUR"}`, "key-2")
+			}()
+		}
+		wg.Wait()
+
+		res := serveWallet(server, http.MethodGet, id, "", "")
+		if got := decodeWallet(t, res).Balances[CurrencyEUR]; got != "1" {
+			t.Errorf("got %s EUR want 1", got)
+		}
+	})
+
+	t.Run("replays failures too", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "")
+
+		assertStatus(t, serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "1 BTC"}`, "key-3"), http.StatusUnprocessableEntity)
+		serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "1 BTC"}`, "")
+
+		assertStatus(t, serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "1 BTC"}`, "key-3"), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("refuses a key reused for another request", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		id := openTestWallet(t, server, "1 BTC")
+
+		serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "0.1 BTC"}`, "key-4")
+		res := serveWallet(server, http.MethodPost, id+"/withdraw", `{"amount": "0.2 BTC"}`, "key-4")
+
+		assertStatus(t, res, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("lets a key go when the first request panics", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		calls := 0
+		handler := server.idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		func() {
+			defer func() { recover() }()
+			serveWallet(handler, http.MethodPost, "/wallets", "", "key-6")
+		}()
+		res := serveWallet(handler, http.MethodPost, "/wallets", "", "key-6")
+
+		assertStatus(t, res, http.StatusNoContent)
+		if calls != 2 {
+			t.Errorf("got %d calls want 2", calls)
+		}
+	})
+
+	t.Run("forgets keys after a while", func(t *testing.T) {
+		server := NewWalletServer(NewInMemoryWalletRepository())
+		server.idempotencyTTL = 0
+		id := openTestWallet(t, server, "")
+
+		serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "1 USD"}`, "key-5")
+		serveWallet(server, http.MethodPost, id+"/deposit", `{"amount": "1 USD"}`, "key-5")
+
+		res := serveWallet(server, http.MethodGet, id, "", "")
+		if got := decodeWallet(t, res).Balances[CurrencyUSD]; got != "2" {
+			t.Errorf("got %s USD want 2", got)
+		}
+		if len(server.expiring) != 1 || len(server.idempotent) != 1 {
+			t.Errorf("kept %d keys in %d places, want only the latest", len(server.idempotent), len(server.expiring))
+		}
+	})
+}
+
+func serveWallet(server http.Handler, method, path, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	return res
+}
+
+func openTestWallet(t *testing.T, server http.Handler, opening string) string {
+	t.Helper()
+	body := ""
+	if opening != "" {
+		body = `{"opening": "` + opening + `"}`
+	}
+	res := serveWallet(server, http.MethodPost, "/wallets", body, "")
+	assertStatus(t, res, http.StatusCreated)
+	return res.Header().Get("Location")
+}
+
+func decodeWallet(t *testing.T, res *httptest.ResponseRecorder) walletResponse {
+	t.Helper()
+	var got walletResponse
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatalf("unable to parse %q: %v", res.Body, err)
+	}
+	return got
+}
+
+func assertStatus(t *testing.T, res *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if res.Code != want {
+		t.Errorf("got status %d want %d: %s", res.Code, want, res.Body)
+	}
+}
@@ -0,0 +1,75 @@
+package pointers
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrSameWallet = errors.New("cannot transfer to the same wallet")
+
+var lastWalletID atomic.Uint64
+
+// ID identifies the wallet. It is handed out the first time it's asked
+// for, so zero-value wallets get one too.
+func (w *Wallet) ID() uint64 {
+	if id := w.id.Load(); id != 0 {
+		return id
+	}
+	w.id.CompareAndSwap(0, lastWalletID.Add(1))
+	return w.id.Load()
+}
+
+// reserveWalletID stops new wallets being handed an ID that a stored
+// wallet already has.
+func reserveWalletID(id uint64) {
+	for {
+		last := lastWalletID.Load()
+		if last >= id || lastWalletID.CompareAndSwap(last, id) {
+			return
+		}
+	}
+}
+
+// Transfer moves amount between wallets with both locked, so neither
+// wallet is ever seen with the money in both or neither. Wallets are
+// always locked lowest ID first, so transfers crossing in opposite
+// directions can't deadlock.
+func Transfer(from, to *Wallet, amount Bitcoin) error {
+	return TransferMoney(from, to, amount.Money())
+}
+
+// TransferMoney is Transfer for any currency.
+func TransferMoney(from, to *Wallet, amount Money) error {
+	return transfer(from, to, amount, amount)
+}
+
+// transfer takes out from one wallet and pays in to the other, which
+// differ only when the money is exchanged on the way. Two loaded copies
+// of one stored wallet share its ID, so they count as the same wallet.
+func transfer(from, to *Wallet, out, in Money) error {
+	if from.ID() == to.ID() {
+		return ErrSameWallet
+	}
+	if err := checkAmount(out); err != nil {
+		return err
+	}
+
+	first, second := from, to
+	if first.ID() > second.ID() {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	// Check the deposit can't fail before taking anything out.
+	if err := to.canDeposit(in); err != nil {
+		return err
+	}
+	if err := from.withdraw(out, fmt.Sprintf("transfer to wallet %d", to.ID())); err != nil {
+		return err
+	}
+	return to.deposit(in, fmt.Sprintf("transfer from wallet %d", from.ID()))
+}
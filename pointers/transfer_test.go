@@ -0,0 +1,88 @@
+package pointers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestTransfer(t *testing.T) {
+	t.Run("moves funds between wallets", func(t *testing.T) {
+		from, to := NewWallet(Bitcoin(20)), NewWallet(Bitcoin(5))
+
+		err := Transfer(from, to, Bitcoin(15))
+
+		assertNoError(t, err)
+		assertBalance(t, from, Bitcoin(5))
+		assertBalance(t, to, Bitcoin(20))
+		assertTransactionIDs(t, from.Transactions(TransactionFilter{MemoContains: "transfer to"}), 2)
+		assertTransactionIDs(t, to.Transactions(TransactionFilter{MemoContains: "transfer from"}), 2)
+	})
+
+	t.Run("rejects bad transfers without moving anything", func(t *testing.T) {
+		from, to := NewWallet(Bitcoin(20)), NewWallet(Bitcoin(5))
+		cases := []struct {
+			name   string
+			to     *Wallet
+			amount Bitcoin
+			want   error
+		}{
+			{"insufficient funds", to, Bitcoin(21), ErrInsufficientFunds},
+			{"same wallet", from, Bitcoin(1), ErrSameWallet},
+			{"zero amount", to, Bitcoin(0), ErrInvalidAmount},
+			{"negative amount", to, Bitcoin(-3), ErrInvalidAmount},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				if err := Transfer(from, c.to, c.amount); !errors.Is(err, c.want) {
+					t.Errorf("got %v want %v", err, c.want)
+				}
+				assertBalance(t, from, Bitcoin(20))
+				assertBalance(t, to, Bitcoin(5))
+			})
+		}
+	})
+
+	t.Run("rejects transfers between copies of one wallet", func(t *testing.T) {
+		repo := NewInMemoryWalletRepository()
+		wallet := NewWallet(Bitcoin(20))
+		assertNoError(t, repo.Save(wallet))
+		first, _ := repo.Load(wallet.ID())
+		second, _ := repo.Load(wallet.ID())
+
+		if err := Transfer(first, second, Bitcoin(1)); !errors.Is(err, ErrSameWallet) {
+			t.Errorf("got %v want ErrSameWallet", err)
+		}
+	})
+
+	t.Run("gives every wallet its own ID", func(t *testing.T) {
+		a, b := &Wallet{}, &Wallet{}
+		if a.ID() == 0 || a.ID() == b.ID() || a.ID() != a.ID() {
+			t.Errorf("got IDs %d and %d", a.ID(), b.ID())
+		}
+	})
+
+	t.Run("crossing transfers neither deadlock nor lose money", func(t *testing.T) {
+		a, b := NewWallet(Bitcoin(1000)), NewWallet(Bitcoin(1000))
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				Transfer(a, b, Bitcoin(3))
+			}()
+			go func() {
+				defer wg.Done()
+				Transfer(b, a, Bitcoin(2))
+			}()
+		}
+		wg.Wait()
+
+		if total := a.Balance() + b.Balance(); total != 2000 {
+			t.Errorf("got %s across both wallets want 2000 BTC", total)
+		}
+		assertBalance(t, a, Bitcoin(800))
+		assertLedgerBalances(t, a)
+		assertLedgerBalances(t, b)
+	})
+}
@@ -2,35 +2,152 @@ package pointers
 
 import (
 	"errors"
-	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var ErrInsufficientFunds = errors.New("cannot withdraw with insufficient fonds")
 
-type Bitcoin int
-
+// Wallet keeps a balance in each currency and the ledger of every change
+// to them. It is safe for concurrent use; a Wallet must not be copied
+// after first use. The Bitcoin methods work on the BTC balance.
 type Wallet struct {
-	balance Bitcoin
+	mu       sync.Mutex
+	balances Balances
+	ledger   []Transaction
+	version  uint64
+	holds    []Hold
+	lastHold HoldID
+	limits   Limits
+
+	id atomic.Uint64
+
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+// NewWallet opens a wallet, recording any opening balance as its first
+// deposit so the ledger always explains the balance. It panics if the
+// opening balance is negative.
+func NewWallet(opening Bitcoin) *Wallet {
+	w := &Wallet{}
+	if opening != 0 {
+		if err := w.DepositWithMemo(opening, "opening balance"); err != nil {
+			panic("pointers: invalid opening balance " + opening.String())
+		}
+	}
+	return w
 }
 
 func (w *Wallet) Balance() Bitcoin {
-	return w.balance
+	return Bitcoin(w.BalanceOf(CurrencyBTC).Amount)
+}
+
+func (w *Wallet) BalanceOf(c Currency) Money {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Money{Amount: w.balances[c], Currency: c}
 }
 
-func (w *Wallet) Deposit(amount Bitcoin) {
-	w.balance += amount
+// Balances returns a copy of every balance the wallet holds.
+func (w *Wallet) Balances() Balances {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make(Balances, len(w.balances))
+	for c, amount := range w.balances {
+		out[c] = amount
+	}
+	return out
+}
 
+func (w *Wallet) Deposit(amount Bitcoin) error {
+	return w.DepositWithMemo(amount, "")
+}
+
+func (w *Wallet) DepositWithMemo(amount Bitcoin, memo string) error {
+	return w.DepositMoney(amount.Money(), memo)
+}
+
+func (w *Wallet) DepositMoney(m Money, memo string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.deposit(m, memo)
 }
 
 func (w *Wallet) Withdraw(amount Bitcoin) error {
-	if amount > w.balance {
+	return w.WithdrawWithMemo(amount, "")
+}
+
+func (w *Wallet) WithdrawWithMemo(amount Bitcoin, memo string) error {
+	return w.WithdrawMoney(amount.Money(), memo)
+}
+
+// WithdrawMoney checks and takes the funds under one lock, so two
+// withdrawals can't both pass the check and overdraw the wallet.
+func (w *Wallet) WithdrawMoney(m Money, memo string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.withdraw(m, memo)
+}
+
+// checkAmount rejects unknown currencies and amounts that aren't positive.
+func checkAmount(m Money) error {
+	if _, err := m.Currency.Decimals(); err != nil {
+		return err
+	}
+	if m.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// canDeposit, deposit and withdraw work on the balances. The caller
+// holds w.mu.
+func (w *Wallet) canDeposit(m Money) error {
+	if err := checkAmount(m); err != nil {
+		return err
+	}
+	_, err := addInt64(w.balances[m.Currency], m.Amount)
+	return err
+}
+
+func (w *Wallet) deposit(m Money, memo string) error {
+	if err := w.canDeposit(m); err != nil {
+		return err
+	}
+	if w.balances == nil {
+		w.balances = make(Balances)
+	}
+	w.balances[m.Currency] += m.Amount
+	w.record(KindDeposit, m, memo)
+	return nil
+}
+
+// withdraw takes money out, as long as it isn't held and is within the
+// wallet's limits.
+func (w *Wallet) withdraw(m Money, memo string) error {
+	if err := checkAmount(m); err != nil {
+		return err
+	}
+	if err := w.checkLimits(m); err != nil {
+		return err
+	}
+	if m.Amount > w.available(m.Currency) {
 		return ErrInsufficientFunds
 	}
-	w.balance -= amount
+	w.take(m, memo)
 	return nil
+}
 
+func (w *Wallet) take(m Money, memo string) {
+	w.balances[m.Currency] -= m.Amount
+	w.record(KindWithdrawal, m, memo)
 }
 
-func (b Bitcoin) String() string {
-	return fmt.Sprintf("%d BTC", b)
+func (w *Wallet) clock() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
 }
@@ -1,20 +1,25 @@
 package pointers
 
 import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
 func TestWallet(t *testing.T) {
 
 	t.Run("Deposit", func(t *testing.T) {
-		wallet := Wallet{}
-		wallet.Deposit(Bitcoin(10))
+		wallet := &Wallet{}
+		err := wallet.Deposit(Bitcoin(10))
 		assertBalance(t, wallet, Bitcoin(10))
+		assertNoError(t, err)
 	})
 
 	t.Run("Withdraw with funds", func(t *testing.T) {
 		startingBalance := Bitcoin(20)
-		wallet := Wallet{startingBalance}
+		wallet := NewWallet(startingBalance)
 		err := wallet.Withdraw(Bitcoin(10))
 
 		assertBalance(t, wallet, Bitcoin(10))
@@ -23,15 +28,31 @@ func TestWallet(t *testing.T) {
 
 	t.Run("Withdraw insufficient funds", func(t *testing.T) {
 		startingBalance := Bitcoin(20)
-		wallet := Wallet{startingBalance}
+		wallet := NewWallet(startingBalance)
 		err := wallet.Withdraw(Bitcoin(100))
 
 		assertBalance(t, wallet, startingBalance)
 		assertError(t, err, ErrInsufficientFunds.Error())
 	})
+
+	t.Run("Rejects amounts that aren't positive", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(20))
+
+		assertError(t, wallet.Deposit(Bitcoin(-5)), ErrInvalidAmount.Error())
+		assertError(t, wallet.Withdraw(Bitcoin(-5)), ErrInvalidAmount.Error())
+		assertError(t, wallet.Deposit(Bitcoin(0)), ErrInvalidAmount.Error())
+		assertBalance(t, wallet, Bitcoin(20))
+	})
+
+	t.Run("Rejects deposits that would overflow", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(math.MaxInt64))
+
+		assertError(t, wallet.Deposit(Satoshi), ErrOverflow.Error())
+		assertBalance(t, wallet, Bitcoin(math.MaxInt64))
+	})
 }
 
-func assertBalance(t *testing.T, wallet Wallet, want Bitcoin) {
+func assertBalance(t *testing.T, wallet *Wallet, want Bitcoin) {
 	t.Helper()
 	got := wallet.Balance()
 	if got != want {
@@ -54,3 +75,71 @@ func assertNoError(t *testing.T, got error) {
 		t.Fatal("an error is not expected")
 	}
 }
+
+func TestWalletConcurrency(t *testing.T) {
+	t.Run("concurrent deposits all land", func(t *testing.T) {
+		wallet := &Wallet{}
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wallet.Deposit(Bitcoin(1))
+			}()
+		}
+		wg.Wait()
+
+		assertBalance(t, wallet, Bitcoin(100))
+		assertLedgerBalances(t, wallet)
+	})
+
+	t.Run("concurrent withdrawals never overdraw", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(50))
+		var wg sync.WaitGroup
+		var succeeded atomic.Int64
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if wallet.Withdraw(Bitcoin(1)) == nil {
+					succeeded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assertBalance(t, wallet, Bitcoin(0))
+		if got := succeeded.Load(); got != 50 {
+			t.Errorf("got %d successful withdrawals want 50", got)
+		}
+	})
+
+	t.Run("mixed deposits and withdrawals balance out", func(t *testing.T) {
+		wallet := NewWallet(Bitcoin(10))
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				wallet.Deposit(Bitcoin(2))
+			}()
+			go func() {
+				defer wg.Done()
+				for wallet.Withdraw(Bitcoin(2)) != nil {
+					runtime.Gosched()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assertBalance(t, wallet, Bitcoin(10))
+		assertLedgerBalances(t, wallet)
+	})
+}
+
+func assertLedgerBalances(t *testing.T, wallet *Wallet) {
+	t.Helper()
+	balances, err := Replay(wallet.Transactions(TransactionFilter{}))
+	assertNoError(t, err)
+	assertBalance(t, wallet, Bitcoin(balances[CurrencyBTC]))
+}